@@ -0,0 +1,127 @@
+package chaintrackdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	serrors "errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidBackup is returned when restoring from a stream that is not a valid backup.
+var ErrInvalidBackup = store.ErrInvalidBackup
+
+// Backup writes a consistent copy of the last commited state to w.
+// Only blocks reachable from the last commited root are written.
+// Transactions can be commited while the backup is running.
+func (d *DB) Backup(ctx context.Context, w io.Writer) error {
+	err := d.s.Backup(ctx, w)
+	if err != nil {
+		return errors.Wrap(err, "while writing backup")
+	}
+	return nil
+}
+
+// ErrInvalidBackupToken is returned when an incremental backup is taken with a token
+// that was not returned by IncrementalBackup of the database.
+var ErrInvalidBackupToken = serrors.New("invalid backup token")
+
+// backupTokenSize is the size of a decoded backup token:
+// store id, root of the backup and crc32 of both.
+const backupTokenSize = store.StoreIDSize + 8 + 4
+
+func encodeBackupToken(id store.StoreID, root store.Address) string {
+	t := make([]byte, backupTokenSize)
+	copy(t, id[:])
+	binary.BigEndian.PutUint64(t[store.StoreIDSize:], uint64(root))
+	binary.BigEndian.PutUint32(t[store.StoreIDSize+8:], crc32.ChecksumIEEE(t[:store.StoreIDSize+8]))
+	return hex.EncodeToString(t)
+}
+
+// decodeBackupToken returns the root of the backup that returned the token.
+// Tokens that are corrupted or were returned by another store are rejected.
+func decodeBackupToken(id store.StoreID, token string) (store.Address, error) {
+	t, err := hex.DecodeString(token)
+	if err != nil || len(t) != backupTokenSize {
+		return store.NilAddress, ErrInvalidBackupToken
+	}
+
+	if crc32.ChecksumIEEE(t[:store.StoreIDSize+8]) != binary.BigEndian.Uint32(t[store.StoreIDSize+8:]) {
+		return store.NilAddress, ErrInvalidBackupToken
+	}
+
+	if !bytes.Equal(t[:store.StoreIDSize], id[:]) {
+		return store.NilAddress, ErrInvalidBackupToken
+	}
+
+	return store.Address(binary.BigEndian.Uint64(t[store.StoreIDSize:])), nil
+}
+
+// IncrementalBackup writes the changes made since the backup that returned sinceToken to w
+// and returns the token of this backup.
+// An empty sinceToken starts a new chain with a full backup.
+// Tokens returned by other databases, including backups and replicas of this one,
+// are rejected with ErrInvalidBackupToken.
+// The chain of backups can be restored with RestoreChain.
+func (d *DB) IncrementalBackup(ctx context.Context, sinceToken string, w io.Writer) (string, error) {
+	since := store.NilAddress
+
+	if sinceToken != "" {
+		a, err := decodeBackupToken(d.s.ID(), sinceToken)
+		if err != nil {
+			return "", err
+		}
+		since = a
+	}
+
+	if since > d.s.LastCommitAddress() {
+		return "", ErrInvalidBackupToken
+	}
+
+	root, err := d.s.IncrementalBackup(ctx, since, w)
+	if err != nil {
+		return "", errors.Wrap(err, "while writing incremental backup")
+	}
+
+	return encodeBackupToken(d.s.ID(), root), nil
+}
+
+// BackupTo creates a compacted copy of the last commited state as a new database in dir.
+func (d *DB) BackupTo(dir string) error {
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(d.Backup(context.Background(), pw))
+	}()
+
+	err := Restore(pr, dir)
+	pr.CloseWithError(err)
+
+	return err
+}
+
+// Restore creates a new database in dir from a backup written by Backup.
+func Restore(r io.Reader, dir string) error {
+	_, err := store.Restore(r, dir)
+	if err != nil {
+		return errors.Wrap(err, "while restoring backup")
+	}
+	return nil
+}
+
+// RestoreChain creates a new database in dir from a chain of backups written by IncrementalBackup,
+// starting with the full backup.
+// Memory proportional to the number of blocks in the chain is used,
+// roughly 50 bytes per block, until the whole chain is restored.
+func RestoreChain(dir string, backups ...io.Reader) error {
+	_, err := store.RestoreChain(dir, backups...)
+	if err != nil {
+		return errors.Wrap(err, "while restoring backups")
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package chaintrackdb_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackup(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for i := 0; i < 20; i++ {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.PutAll(fmt.Sprintf("m/%d", i%5), make([]byte, 1000*i))
+		})
+		require.NoError(t, err)
+	}
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		return tx.Copy("m", "copy")
+	})
+	require.NoError(t, err)
+
+	stats, err := db.Stats()
+	require.NoError(t, err)
+
+	td, cleanupDir := NewTempDir(t)
+	defer cleanupDir()
+
+	verify := func(t *testing.T, dir string) {
+		report, err := chaintrackdb.Check(dir, false)
+		require.NoError(t, err)
+		require.Empty(t, report.Problems)
+
+		rdb, err := chaintrackdb.OpenReadOnly(dir)
+		require.NoError(t, err)
+		defer rdb.Close()
+
+		err = rdb.ReadTransaction(ctx, func(tx *chaintrackdb.ReadTransaction) error {
+			for _, p := range []string{"m/4", "copy/4"} {
+				d, err := tx.Get(p)
+				require.NoError(t, err)
+				require.Len(t, d, 19000)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+
+		rstats, err := rdb.Stats()
+		require.NoError(t, err)
+		require.Equal(t, stats.LiveBytes, rstats.LiveBytes)
+		require.Equal(t, rstats.LiveBytes, rstats.OccupiedBytes)
+	}
+
+	t.Run("when I backup the database to a stream", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := db.Backup(ctx, buf)
+		require.NoError(t, err)
+
+		t.Run("and a transaction is commited after the backup", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Delete("m")
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then the restored database should contain the backed up state", func(t *testing.T) {
+			dir := filepath.Join(td, "restored")
+			err := chaintrackdb.Restore(bytes.NewReader(buf.Bytes()), dir)
+			require.NoError(t, err)
+			verify(t, dir)
+		})
+
+		t.Run("then restoring into an existing database should fail", func(t *testing.T) {
+			err := chaintrackdb.Restore(bytes.NewReader(buf.Bytes()), filepath.Join(td, "restored"))
+			require.Error(t, err)
+		})
+
+		t.Run("then restoring a corrupted backup should fail", func(t *testing.T) {
+			corrupted := append([]byte{}, buf.Bytes()...)
+			corrupted[len(corrupted)/2]++
+			err := chaintrackdb.Restore(bytes.NewReader(corrupted), filepath.Join(td, "corrupted"))
+			require.Equal(t, chaintrackdb.ErrInvalidBackup, errors.Cause(err))
+		})
+
+		t.Run("then restoring a truncated backup should fail", func(t *testing.T) {
+			err := chaintrackdb.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-100]), filepath.Join(td, "truncated"))
+			require.Equal(t, chaintrackdb.ErrInvalidBackup, errors.Cause(err))
+		})
+	})
+
+	t.Run("when I backup the database to a directory", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Copy("copy", "m")
+		})
+		require.NoError(t, err)
+
+		dir := filepath.Join(td, "backup")
+		err = db.BackupTo(dir)
+		require.NoError(t, err)
+
+		t.Run("then the directory should contain a copy of the database", func(t *testing.T) {
+			verify(t, dir)
+		})
+	})
+}
+
+func TestIncrementalBackup(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	td, cleanupDir := NewTempDir(t)
+	defer cleanupDir()
+
+	put := func(t *testing.T, from, to int) {
+		for i := from; i < to; i++ {
+			err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.PutAll(fmt.Sprintf("m/%d", i), make([]byte, 10000))
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	put(t, 0, 50)
+
+	full := new(bytes.Buffer)
+	token, err := db.IncrementalBackup(ctx, "", full)
+	require.NoError(t, err)
+
+	backups := []*bytes.Buffer{full}
+
+	t.Run("when I take incremental backups after changes and compaction", func(t *testing.T) {
+		put(t, 50, 52)
+
+		inc := new(bytes.Buffer)
+		token, err = db.IncrementalBackup(ctx, token, inc)
+		require.NoError(t, err)
+		backups = append(backups, inc)
+
+		t.Run("then the incremental backup should be smaller than the full one", func(t *testing.T) {
+			require.Less(t, inc.Len(), full.Len()/2)
+		})
+
+		require.NoError(t, db.Compact())
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Delete("m/3")
+		})
+		require.NoError(t, err)
+
+		inc = new(bytes.Buffer)
+		token, err = db.IncrementalBackup(ctx, token, inc)
+		require.NoError(t, err)
+		backups = append(backups, inc)
+
+		inc = new(bytes.Buffer)
+		_, err = db.IncrementalBackup(ctx, token, inc)
+		require.NoError(t, err)
+		backups = append(backups, inc)
+
+		t.Run("then the restored chain should contain the last state", func(t *testing.T) {
+			readers := []io.Reader{}
+			for _, b := range backups {
+				readers = append(readers, bytes.NewReader(b.Bytes()))
+			}
+
+			dir := filepath.Join(td, "restored")
+			err := chaintrackdb.RestoreChain(dir, readers...)
+			require.NoError(t, err)
+
+			report, err := chaintrackdb.Check(dir, false)
+			require.NoError(t, err)
+			require.Empty(t, report.Problems)
+
+			rdb, err := chaintrackdb.OpenReadOnly(dir)
+			require.NoError(t, err)
+			defer rdb.Close()
+
+			err = rdb.ReadTransaction(ctx, func(tx *chaintrackdb.ReadTransaction) error {
+				cnt, err := tx.Count("m")
+				require.NoError(t, err)
+				require.Equal(t, uint64(51), cnt)
+
+				ex, err := tx.Exists("m/3")
+				require.NoError(t, err)
+				require.False(t, ex)
+
+				d, err := tx.Get("m/51")
+				require.NoError(t, err)
+				require.Len(t, d, 10000)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then restoring the chain without an incremental backup should fail", func(t *testing.T) {
+			err := chaintrackdb.RestoreChain(
+				filepath.Join(td, "incomplete"),
+				bytes.NewReader(backups[0].Bytes()),
+				bytes.NewReader(backups[2].Bytes()),
+			)
+			require.Equal(t, chaintrackdb.ErrInvalidBackup, errors.Cause(err))
+		})
+	})
+
+	t.Run("when I use an invalid token", func(t *testing.T) {
+		_, err := db.IncrementalBackup(ctx, "xyz", new(bytes.Buffer))
+		t.Run("then I should get ErrInvalidBackupToken", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrInvalidBackupToken, err)
+		})
+	})
+
+	t.Run("when I use a modified token", func(t *testing.T) {
+		modified := []byte(token)
+		if modified[len(modified)/2] == '0' {
+			modified[len(modified)/2] = '1'
+		} else {
+			modified[len(modified)/2] = '0'
+		}
+
+		_, err := db.IncrementalBackup(ctx, string(modified), new(bytes.Buffer))
+		t.Run("then I should get ErrInvalidBackupToken", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrInvalidBackupToken, err)
+		})
+	})
+
+	t.Run("when I use a token of another database", func(t *testing.T) {
+		other, cleanupOther := NewEmptyDB(t)
+		defer cleanupOther()
+
+		otherToken, err := other.IncrementalBackup(ctx, "", new(bytes.Buffer))
+		require.NoError(t, err)
+
+		_, err = db.IncrementalBackup(ctx, otherToken, new(bytes.Buffer))
+		t.Run("then I should get ErrInvalidBackupToken", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrInvalidBackupToken, err)
+		})
+	})
+}
+
+func TestIncrementalBackupAfterReopen(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+
+	token, err := db.IncrementalBackup(ctx, "", new(bytes.Buffer))
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	t.Run("when I reopen the database", func(t *testing.T) {
+		db, err = chaintrackdb.Open(td)
+		require.NoError(t, err)
+		defer db.Close()
+
+		t.Run("then the token should still be accepted", func(t *testing.T) {
+			_, err = db.IncrementalBackup(ctx, token, new(bytes.Buffer))
+			require.NoError(t, err)
+		})
+	})
+}
@@ -0,0 +1,150 @@
+package chaintrackdb
+
+import (
+	"context"
+	serrors "errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBatchSize is the default maximal number of functions combined into one batch.
+const DefaultMaxBatchSize = 1000
+
+// DefaultMaxBatchDelay is the default time a batch waits for more functions before it is run.
+const DefaultMaxBatchDelay = 10 * time.Millisecond
+
+// Batch calls fn as part of a batch. It behaves like WriteTransaction except:
+//
+// 1. concurrent Batch calls are combined into a single write transaction and a single commit,
+//
+// 2. fn may be called multiple times, regardless of whether it returns an error.
+//
+// If fn returns an error, the batch is rolled back and re-run without it,
+// while fn is re-run on its own and its error is returned to the caller.
+//
+// The batch is run once it has MaxBatchSize functions or after MaxBatchDelay,
+// whichever comes first. If ctx is done before fn has been run, fn is skipped
+// and the context error is returned.
+func (d *DB) Batch(ctx context.Context, fn func(tx *WriteTransaction) error) error {
+	errCh := make(chan error, 1)
+
+	d.batchMu.Lock()
+	if d.batch == nil || len(d.batch.calls) >= d.MaxBatchSize {
+		d.batch = &batch{
+			db: d,
+		}
+		d.batch.timer = time.AfterFunc(d.MaxBatchDelay, d.batch.trigger)
+	}
+	d.batch.calls = append(d.batch.calls, call{ctx: ctx, fn: fn, err: errCh})
+	if len(d.batch.calls) >= d.MaxBatchSize {
+		go d.batch.trigger()
+	}
+	d.batchMu.Unlock()
+
+	err := <-errCh
+	if err == errTrySolo {
+		err = d.WriteTransaction(ctx, fn)
+	}
+	return err
+}
+
+type call struct {
+	ctx context.Context
+	fn  func(*WriteTransaction) error
+	err chan<- error
+}
+
+type batch struct {
+	db    *DB
+	timer *time.Timer
+	start sync.Once
+	calls []call
+}
+
+// trigger runs the batch if it hasn't already been run.
+func (b *batch) trigger() {
+	b.start.Do(b.run)
+}
+
+// run performs the transactions in the batch and communicates results
+// back to Batch callers.
+func (b *batch) run() {
+	b.db.batchMu.Lock()
+	b.timer.Stop()
+	// Make sure no new work is added to this batch, but don't break
+	// other batches.
+	if b.db.batch == b {
+		b.db.batch = nil
+	}
+	b.db.batchMu.Unlock()
+
+retry:
+	for len(b.calls) > 0 {
+		failIdx := -1
+		// the batch transaction is shared, so it is not bound to the context of any of the callers
+		err := b.db.WriteTransaction(context.Background(), func(tx *WriteTransaction) error {
+			for i, c := range b.calls {
+				err := c.ctx.Err()
+				if err == nil {
+					err = safelyCall(c.fn, tx)
+				}
+				if err != nil {
+					failIdx = i
+					return err
+				}
+			}
+			return nil
+		})
+
+		if failIdx >= 0 {
+			// take the failing call out of the batch. it's safe to
+			// shorten b.calls here because db.batch no longer points
+			// to us.
+			c := b.calls[failIdx]
+			b.calls[failIdx], b.calls = b.calls[len(b.calls)-1], b.calls[:len(b.calls)-1]
+			if c.ctx.Err() != nil {
+				c.err <- c.ctx.Err()
+			} else {
+				// tell the submitter re-run it solo, continue with the rest of the batch
+				c.err <- errTrySolo
+			}
+			continue retry
+		}
+
+		if err == ErrConflict {
+			continue retry
+		}
+
+		// pass success, or commit error, to all callers
+		for _, c := range b.calls {
+			c.err <- err
+		}
+		break retry
+	}
+}
+
+// errTrySolo is a special sentinel error value used for signaling that a
+// transaction function should be re-run. It should never be seen by
+// callers.
+var errTrySolo = serrors.New("batch function returned an error and should be re-run solo")
+
+type panicked struct {
+	reason interface{}
+}
+
+func (p panicked) Error() string {
+	if err, ok := p.reason.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("panic: %v", p.reason)
+}
+
+func safelyCall(fn func(*WriteTransaction) error, tx *WriteTransaction) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = panicked{p}
+		}
+	}()
+	return fn(tx)
+}
@@ -0,0 +1,97 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	db.MaxBatchDelay = 50 * time.Millisecond
+
+	ctx := context.Background()
+
+	t.Run("when I call batch concurrently", func(t *testing.T) {
+		numberOfCalls := 20
+
+		txMu := new(sync.Mutex)
+		transactions := map[*chaintrackdb.WriteTransaction]bool{}
+
+		wg := new(sync.WaitGroup)
+		errs := make([]error, numberOfCalls)
+		failing := errors.New("failing function")
+
+		for i := 0; i < numberOfCalls; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = db.Batch(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					txMu.Lock()
+					transactions[tx] = true
+					txMu.Unlock()
+					if i == 3 {
+						return failing
+					}
+					return tx.Put(fmt.Sprintf("key%d", i), []byte{byte(i)})
+				})
+			}(i)
+		}
+
+		wg.Wait()
+
+		t.Run("then the failing function should return its error", func(t *testing.T) {
+			require.Equal(t, failing, errs[3])
+		})
+
+		t.Run("then all other functions should succeed", func(t *testing.T) {
+			for i, err := range errs {
+				if i == 3 {
+					continue
+				}
+				require.NoError(t, err)
+			}
+		})
+
+		t.Run("then functions should be combined into fewer transactions", func(t *testing.T) {
+			require.True(t, len(transactions) < numberOfCalls)
+		})
+
+		t.Run("then values of all successful functions should be stored", func(t *testing.T) {
+			err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				for i := 0; i < numberOfCalls; i++ {
+					exists, err := tx.Exists(fmt.Sprintf("key%d", i))
+					require.NoError(t, err)
+					require.Equal(t, i != 3, exists)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I call batch with a cancelled context", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		called := false
+		err := db.Batch(cctx, func(tx *chaintrackdb.WriteTransaction) error {
+			called = true
+			return nil
+		})
+
+		t.Run("then the context error should be returned", func(t *testing.T) {
+			require.Equal(t, context.Canceled, err)
+			require.False(t, called)
+		})
+	})
+
+}
@@ -0,0 +1,103 @@
+package btree
+
+import (
+	"bytes"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// CheckNode validates the btree node at a: keys must be sorted, the node must have
+// a value for every key and a child node around every key or no child nodes at all,
+// the stored count must match the counts of the child nodes and
+// the keys of every child node must be between the keys of the node around it.
+// It can be used as a store.BlockChecker.
+func CheckNode(r store.Reader, a store.Address, br store.BlockReader) error {
+	n := &node{
+		m:       M,
+		address: a,
+		reader:  r,
+	}
+
+	err := n.load()
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(n.KVS); i++ {
+		if bytes.Compare(n.KVS[i-1].Key, n.KVS[i].Key) >= 0 {
+			return errors.Errorf("key %d is not greater than key %d", i, i-1)
+		}
+	}
+
+	count := uint64(len(n.KVS))
+
+	for i, c := range n.Children {
+		err = c.load()
+		if err != nil {
+			return errors.Wrapf(err, "while loading child node %d", i)
+		}
+
+		count += c.Count
+
+		if c.Count == 0 {
+			continue
+		}
+
+		if i > 0 {
+			min, err := c.minKey()
+			if err != nil {
+				return err
+			}
+			if bytes.Compare(min, n.KVS[i-1].Key) <= 0 {
+				return errors.Errorf("child node %d has a key that is not greater than key %d", i, i-1)
+			}
+		}
+
+		if i < len(n.KVS) {
+			max, err := c.maxKey()
+			if err != nil {
+				return err
+			}
+			if bytes.Compare(max, n.KVS[i].Key) >= 0 {
+				return errors.Errorf("child node %d has a key that is not less than key %d", i, i)
+			}
+		}
+	}
+
+	if count != n.Count {
+		return errors.Errorf("count is %d, should be %d", n.Count, count)
+	}
+
+	return nil
+}
+
+// minKey returns the lowest key of the loaded node's subtree.
+func (n *node) minKey() ([]byte, error) {
+	for !n.isLeaf() {
+		n = n.Children[0]
+		err := n.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(n.KVS) == 0 {
+		return nil, errors.New("empty leaf node")
+	}
+	return n.KVS[0].Key, nil
+}
+
+// maxKey returns the highest key of the loaded node's subtree.
+func (n *node) maxKey() ([]byte, error) {
+	for !n.isLeaf() {
+		n = n.Children[len(n.Children)-1]
+		err := n.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(n.KVS) == 0 {
+		return nil, errors.New("empty leaf node")
+	}
+	return n.KVS[len(n.KVS)-1].Key, nil
+}
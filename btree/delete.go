@@ -0,0 +1,279 @@
+package btree
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// Delete removes the key from the btree and returns the address of the new root.
+// Returns ErrNotFound if the btree does not contain the key.
+func Delete(rw store.ReaderWriter, root store.Address, key []byte) (store.Address, error) {
+	n := &node{
+		m:       M,
+		address: root,
+		reader:  rw,
+		writer:  rw,
+	}
+
+	err := n.delete(key)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	if len(n.KVS) == 0 && !n.isLeaf() {
+		c := n.Children[0]
+		if n.shared && !c.shared {
+			// loading makes sure the child is persisted as a new, shared block
+			err = c.load()
+			if err != nil {
+				return store.NilAddress, err
+			}
+			c.shared = true
+		}
+		n = c
+	}
+
+	return n.persist()
+}
+
+// hasSpareKey returns true if a key can be removed from the node without
+// it having less than the minimal number of keys.
+func (n *node) hasSpareKey() bool {
+	return len(n.KVS) > n.m
+}
+
+// delete removes the key from the subtree.
+// Every node delete descends into has at least one spare key,
+// so removing a key never leaves a node with less than the minimal number of keys.
+func (n *node) delete(key []byte) error {
+	err := n.load()
+	if err != nil {
+		return err
+	}
+
+	idx := sort.Search(len(n.KVS), func(i int) bool {
+		return bytes.Compare(n.KVS[i].Key, key) >= 0
+	})
+
+	found := idx < len(n.KVS) && bytes.Equal(n.KVS[idx].Key, key)
+
+	if n.isLeaf() {
+		if !found {
+			return ErrNotFound
+		}
+		n.KVS = append(n.KVS[:idx:idx], n.KVS[idx+1:]...)
+		n.Count--
+		return nil
+	}
+
+	if found {
+		left := n.Children[idx]
+		right := n.Children[idx+1]
+
+		err = left.load()
+		if err != nil {
+			return err
+		}
+
+		err = right.load()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case left.hasSpareKey():
+			pred, err := left.max()
+			if err != nil {
+				return errors.Wrap(err, "while finding predecessor")
+			}
+			n.KVS[idx] = pred
+			err = left.delete(pred.Key)
+			if err != nil {
+				return err
+			}
+		case right.hasSpareKey():
+			succ, err := right.min()
+			if err != nil {
+				return errors.Wrap(err, "while finding successor")
+			}
+			n.KVS[idx] = succ
+			err = right.delete(succ.Key)
+			if err != nil {
+				return err
+			}
+		default:
+			merged, err := n.mergeChildren(idx)
+			if err != nil {
+				return err
+			}
+			err = merged.delete(key)
+			if err != nil {
+				return err
+			}
+		}
+
+		n.Count--
+		return nil
+	}
+
+	child, err := n.childWithSpareKey(idx)
+	if err != nil {
+		return err
+	}
+
+	err = child.delete(key)
+	if err != nil {
+		return err
+	}
+
+	n.Count--
+
+	return nil
+}
+
+// childWithSpareKey makes sure that the child at idx has a spare key,
+// either by moving a key from one of its siblings or by merging it with a sibling.
+// Returns the child that contains the keys of the original child.
+func (n *node) childWithSpareKey(idx int) (*node, error) {
+	c := n.Children[idx]
+	err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.hasSpareKey() {
+		return c, nil
+	}
+
+	if idx > 0 {
+		ls := n.Children[idx-1]
+		err = ls.load()
+		if err != nil {
+			return nil, err
+		}
+
+		if ls.hasSpareKey() {
+			last := len(ls.KVS) - 1
+			c.KVS = append([]keyValue{n.KVS[idx-1]}, c.KVS...)
+			n.KVS[idx-1] = ls.KVS[last]
+			ls.KVS = ls.KVS[:last:last]
+
+			if !ls.isLeaf() {
+				lastChild := len(ls.Children) - 1
+				c.Children = append([]*node{ls.Children[lastChild]}, c.Children...)
+				ls.Children = ls.Children[:lastChild:lastChild]
+			}
+
+			c.shared = c.shared || ls.shared
+
+			return c, recount(c, ls)
+		}
+	}
+
+	if idx < len(n.Children)-1 {
+		rs := n.Children[idx+1]
+		err = rs.load()
+		if err != nil {
+			return nil, err
+		}
+
+		if rs.hasSpareKey() {
+			c.KVS = append(c.KVS[:len(c.KVS):len(c.KVS)], n.KVS[idx])
+			n.KVS[idx] = rs.KVS[0]
+			rs.KVS = append([]keyValue(nil), rs.KVS[1:]...)
+
+			if !rs.isLeaf() {
+				c.Children = append(c.Children[:len(c.Children):len(c.Children)], rs.Children[0])
+				rs.Children = append([]*node(nil), rs.Children[1:]...)
+			}
+
+			c.shared = c.shared || rs.shared
+
+			return c, recount(c, rs)
+		}
+
+		return n.mergeChildren(idx)
+	}
+
+	return n.mergeChildren(idx - 1)
+}
+
+// mergeChildren merges the child at idx, the key at idx and the child at idx+1 into one node.
+// Both children must be loaded.
+func (n *node) mergeChildren(idx int) (*node, error) {
+	left := n.Children[idx]
+	right := n.Children[idx+1]
+
+	kvs := make([]keyValue, 0, len(left.KVS)+1+len(right.KVS))
+	kvs = append(kvs, left.KVS...)
+	kvs = append(kvs, n.KVS[idx])
+	kvs = append(kvs, right.KVS...)
+
+	var children []*node
+	if !left.isLeaf() {
+		children = make([]*node, 0, len(left.Children)+len(right.Children))
+		children = append(children, left.Children...)
+		children = append(children, right.Children...)
+	}
+
+	merged := &node{
+		m:        n.m,
+		KVS:      kvs,
+		Children: children,
+		Count:    left.Count + 1 + right.Count,
+		reader:   n.reader,
+		writer:   n.writer,
+		address:  store.NilAddress,
+		shared:   left.shared || right.shared,
+	}
+
+	n.KVS = append(n.KVS[:idx:idx], n.KVS[idx+1:]...)
+	n.Children = append(n.Children[:idx:idx], append([]*node{merged}, n.Children[idx+2:]...)...)
+
+	return merged, nil
+}
+
+func (n *node) max() (keyValue, error) {
+	err := n.load()
+	if err != nil {
+		return keyValue{}, err
+	}
+
+	if n.isLeaf() {
+		return n.KVS[len(n.KVS)-1], nil
+	}
+
+	return n.Children[len(n.Children)-1].max()
+}
+
+func (n *node) min() (keyValue, error) {
+	err := n.load()
+	if err != nil {
+		return keyValue{}, err
+	}
+
+	if n.isLeaf() {
+		return n.KVS[0], nil
+	}
+
+	return n.Children[0].min()
+}
+
+// recount updates number of keys in the subtrees of the loaded nodes.
+func recount(nodes ...*node) error {
+	for _, n := range nodes {
+		cnt := uint64(len(n.KVS))
+		for _, c := range n.Children {
+			cc, err := c.subtreeCount()
+			if err != nil {
+				return errors.Wrap(err, "while counting keys of a child")
+			}
+			cnt += cc
+		}
+		n.Count = cnt
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package btree_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelete(t *testing.T) {
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	t.Run("when I delete a key from an empty btree", func(t *testing.T) {
+		a, err := btree.CreateEmpty(ts)
+		require.NoError(t, err)
+
+		_, err = btree.Delete(ts, a, []byte{1})
+
+		t.Run("then ErrNotFound should be returned", func(t *testing.T) {
+			require.Equal(t, btree.ErrNotFound, err)
+		})
+	})
+
+	t.Run("when I delete the only key", func(t *testing.T) {
+		a, err := btree.CreateEmpty(ts)
+		require.NoError(t, err)
+
+		v, err := data.StoreData(ts, []byte{1}, 256, 4)
+		require.NoError(t, err)
+
+		a, err = btree.Put(ts, a, []byte{1}, v)
+		require.NoError(t, err)
+
+		a, err = btree.Delete(ts, a, []byte{1})
+		require.NoError(t, err)
+
+		t.Run("then the btree should be empty", func(t *testing.T) {
+			cnt, err := btree.Count(ts, a)
+			require.NoError(t, err)
+			require.Equal(t, uint64(0), cnt)
+
+			_, err = btree.Get(ts, a, []byte{1})
+			require.Equal(t, btree.ErrNotFound, err)
+		})
+	})
+}
+
+func TestRandomDeletes(t *testing.T) {
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	numberOfKeys := 1024
+
+	a, err := btree.CreateEmpty(ts)
+	require.NoError(t, err)
+
+	keys := make([][]byte, numberOfKeys)
+	values := make([]store.Address, numberOfKeys)
+
+	for i := range keys {
+		keys[i] = []byte{byte(i >> 8), byte(i)}
+		values[i], err = data.StoreData(ts, keys[i], 256, 4)
+		require.NoError(t, err)
+	}
+
+	for _, i := range rand.Perm(numberOfKeys) {
+		a, err = btree.Put(ts, a, keys[i], values[i])
+		require.NoError(t, err)
+	}
+
+	deleted := map[int]bool{}
+
+	for n, i := range rand.Perm(numberOfKeys) {
+		a, err = btree.Delete(ts, a, keys[i])
+		require.NoError(t, err)
+		deleted[i] = true
+
+		if n%64 != 0 {
+			continue
+		}
+
+		cnt, err := btree.Count(ts, a)
+		require.NoError(t, err)
+		require.Equal(t, uint64(numberOfKeys-len(deleted)), cnt)
+
+		for j, k := range keys {
+			v, err := btree.Get(ts, a, k)
+			if deleted[j] {
+				require.Equal(t, btree.ErrNotFound, err)
+				continue
+			}
+			require.NoError(t, err)
+			require.Equal(t, values[j], v)
+		}
+	}
+
+	cnt, err := btree.Count(ts, a)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), cnt)
+
+	t.Run("when I insert into the emptied btree", func(t *testing.T) {
+		a, err = btree.Put(ts, a, keys[0], values[0])
+		require.NoError(t, err)
+
+		v, err := btree.Get(ts, a, keys[0])
+		require.NoError(t, err)
+		require.Equal(t, values[0], v)
+	})
+}
@@ -0,0 +1,58 @@
+package btree
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/draganm/chaintrackdb/store"
+)
+
+// ForEach calls f for every key/value of the btree in the key order.
+// Iteration stops at the first error returned by f, the error is returned by ForEach.
+func ForEach(r store.Reader, root store.Address, f func(key []byte, value store.Address) error) error {
+	return ForEachFrom(r, root, nil, f)
+}
+
+// ForEachFrom calls f for every key/value of the btree with a key equal or greater than from, in the key order.
+// Subtrees containing only smaller keys are not loaded.
+func ForEachFrom(r store.Reader, root store.Address, from []byte, f func(key []byte, value store.Address) error) error {
+	n := &node{
+		m:       M,
+		address: root,
+		reader:  r,
+	}
+
+	return n.forEachFrom(from, f)
+}
+
+func (n *node) forEachFrom(from []byte, f func(key []byte, value store.Address) error) error {
+	err := n.load()
+	if err != nil {
+		return err
+	}
+
+	idx := sort.Search(len(n.KVS), func(i int) bool {
+		return bytes.Compare(n.KVS[i].Key, from) >= 0
+	})
+
+	for i := idx; i < len(n.KVS); i++ {
+		if !n.isLeaf() {
+			err = n.Children[i].forEachFrom(from, f)
+			if err != nil {
+				return err
+			}
+		}
+
+		kv := n.KVS[i]
+		err = f(kv.Key, kv.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	if n.isLeaf() {
+		return nil
+	}
+
+	return n.Children[len(n.Children)-1].forEachFrom(from, f)
+}
@@ -0,0 +1,84 @@
+package btree_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEach(t *testing.T) {
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	a, err := btree.CreateEmpty(ts)
+	require.NoError(t, err)
+
+	t.Run("when I iterate over an empty btree", func(t *testing.T) {
+		called := false
+		err = btree.ForEach(ts, a, func(key []byte, value store.Address) error {
+			called = true
+			return nil
+		})
+		require.NoError(t, err)
+
+		t.Run("then the callback should not be called", func(t *testing.T) {
+			require.False(t, called)
+		})
+	})
+
+	expected := []string{}
+	for _, i := range []int{7, 3, 9, 1, 5, 0, 8, 2, 6, 4} {
+		a, err = btree.Put(ts, a, []byte(fmt.Sprintf("k%d", i)), store.Address(i+1))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 10; i++ {
+		expected = append(expected, fmt.Sprintf("k%d=%d", i, i+1))
+	}
+
+	t.Run("when I iterate over a btree with multiple levels", func(t *testing.T) {
+		visited := []string{}
+		err = btree.ForEach(ts, a, func(key []byte, value store.Address) error {
+			visited = append(visited, fmt.Sprintf("%s=%d", key, value))
+			return nil
+		})
+		require.NoError(t, err)
+
+		t.Run("then all keys should be visited in order", func(t *testing.T) {
+			require.Equal(t, expected, visited)
+		})
+	})
+
+	t.Run("when the callback returns an error", func(t *testing.T) {
+		stop := errors.New("stop")
+		cnt := 0
+		err = btree.ForEach(ts, a, func(key []byte, value store.Address) error {
+			cnt++
+			if cnt == 3 {
+				return stop
+			}
+			return nil
+		})
+
+		t.Run("then the iteration should stop with the error", func(t *testing.T) {
+			require.Equal(t, stop, err)
+			require.Equal(t, 3, cnt)
+		})
+	})
+
+	t.Run("when I iterate from a key", func(t *testing.T) {
+		visited := []string{}
+		err = btree.ForEachFrom(ts, a, []byte("k45"), func(key []byte, value store.Address) error {
+			visited = append(visited, fmt.Sprintf("%s=%d", key, value))
+			return nil
+		})
+		require.NoError(t, err)
+
+		t.Run("then only keys equal or greater than the key should be visited", func(t *testing.T) {
+			require.Equal(t, expected[5:], visited)
+		})
+	})
+}
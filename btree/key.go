@@ -0,0 +1,53 @@
+package btree
+
+import (
+	serrors "errors"
+	"io/ioutil"
+
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// MaxKeySize is the maximal size of a key in bytes.
+const MaxKeySize = 64 * 1024
+
+// ErrKeyTooLarge is returned when a key is larger than MaxKeySize.
+var ErrKeyTooLarge = serrors.New("key is too large")
+
+// keys larger than maxInlineKeySize are stored in overflow data blocks
+// referenced by the node, so a node always fits into a block.
+const maxInlineKeySize = 1024
+
+// overflowKeyMarker is stored instead of the key length for overflow keys.
+const overflowKeyMarker = 0xffff
+
+const overflowSegSize = 16 * 1024
+const overflowFanout = 8
+
+type readerWriter struct {
+	store.Reader
+	store.Writer
+}
+
+func (n *node) writeOverflowKey(key []byte) (store.Address, error) {
+	a, err := data.StoreData(readerWriter{n.reader, n.writer}, key, overflowSegSize, overflowFanout)
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while storing overflow key")
+	}
+	return a, nil
+}
+
+func readOverflowKey(r store.Reader, a store.Address) ([]byte, error) {
+	dr, err := data.NewReader(a, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "while creating overflow key reader")
+	}
+
+	k, err := ioutil.ReadAll(dr)
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading overflow key")
+	}
+
+	return k, nil
+}
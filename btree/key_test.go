@@ -0,0 +1,80 @@
+package btree_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargeKeys(t *testing.T) {
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	a, err := btree.CreateEmpty(ts)
+	require.NoError(t, err)
+
+	largeKey := func(i int) []byte {
+		return append(bytes.Repeat([]byte{'k'}, 5000+i*1000), []byte(fmt.Sprintf("%03d", i))...)
+	}
+
+	keys := [][]byte{}
+	for i := 0; i < 20; i++ {
+		keys = append(keys, largeKey(i), []byte(fmt.Sprintf("small%03d", i)))
+	}
+
+	t.Run("when I put large and small keys", func(t *testing.T) {
+		for i, k := range keys {
+			a, err = btree.Put(ts, a, k, store.Address(i+1))
+			require.NoError(t, err)
+		}
+
+		t.Run("then I should be able to find all keys", func(t *testing.T) {
+			for i, k := range keys {
+				v, err := btree.Get(ts, a, k)
+				require.NoError(t, err)
+				require.Equal(t, store.Address(i+1), v)
+			}
+		})
+
+		t.Run("then iterating should return the whole keys", func(t *testing.T) {
+			found := 0
+			err = btree.ForEach(ts, a, func(key []byte, value store.Address) error {
+				require.Equal(t, keys[value-1], key)
+				found++
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, len(keys), found)
+		})
+	})
+
+	t.Run("when I delete every other key", func(t *testing.T) {
+		for i := 0; i < len(keys); i += 2 {
+			a, err = btree.Delete(ts, a, keys[i])
+			require.NoError(t, err)
+		}
+
+		t.Run("then only the remaining keys should be found", func(t *testing.T) {
+			for i, k := range keys {
+				v, err := btree.Get(ts, a, k)
+				if i%2 == 0 {
+					require.Equal(t, btree.ErrNotFound, err)
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, store.Address(i+1), v)
+			}
+		})
+	})
+
+	t.Run("when I put a key larger than MaxKeySize", func(t *testing.T) {
+		_, err = btree.Put(ts, a, make([]byte, btree.MaxKeySize+1), store.Address(1))
+		t.Run("then I should get ErrKeyTooLarge", func(t *testing.T) {
+			require.Equal(t, btree.ErrKeyTooLarge, err)
+		})
+	})
+}
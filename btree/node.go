@@ -16,9 +16,16 @@ import (
 // ErrNotFound is returned when key cannot be found in the btree
 var ErrNotFound = serrors.New("Not Found")
 
+// ErrNotBTreeNode is returned when the block at the root address is not a btree node
+var ErrNotBTreeNode = serrors.New("Not a BTree node")
+
 type keyValue struct {
 	Key   []byte
 	Value store.Address
+
+	// overflow is the address of the data block containing a key
+	// too large to be stored in the node.
+	overflow store.Address
 }
 
 func (kv *keyValue) MarshalJSON() ([]byte, error) {
@@ -35,6 +42,11 @@ type node struct {
 	writer store.Writer
 
 	address store.Address
+
+	// shared is true if blocks of the node's subtree can be referenced from
+	// outside of it. Nodes built from parts of a shared node and parents
+	// of shared nodes in the same btree must be shared as well.
+	shared bool
 }
 
 type insertResult struct {
@@ -72,6 +84,10 @@ func (n *node) load() error {
 		return err
 	}
 
+	if sr.Type() != store.TypeBTreeNode {
+		return ErrNotBTreeNode
+	}
+
 	d := sr.GetData()
 
 	if len(d) < 8 {
@@ -83,9 +99,13 @@ func (n *node) load() error {
 	d = d[8:]
 
 	n.Count = count
+	n.shared = sr.Shared()
 
 	kvs := []keyValue{}
 
+	// indexes of keys stored in overflow blocks
+	overflowKeys := []int{}
+
 	for len(d) > 0 {
 		if len(d) < 2 {
 			return errors.New("segment data key length must be at least 2 bytes")
@@ -94,6 +114,13 @@ func (n *node) load() error {
 		kl := int(binary.BigEndian.Uint16(d))
 
 		d = d[2:]
+
+		if kl == overflowKeyMarker {
+			overflowKeys = append(overflowKeys, len(kvs))
+			kvs = append(kvs, keyValue{})
+			continue
+		}
+
 		if len(d) < kl {
 			return errors.New("key length is larger than available data")
 		}
@@ -106,7 +133,7 @@ func (n *node) load() error {
 
 	}
 
-	if sr.NumberOfChildren() < len(kvs) {
+	if sr.NumberOfChildren() < len(kvs)+len(overflowKeys) {
 		return errors.New("segment doesn't have enough children for all values")
 	}
 
@@ -114,15 +141,28 @@ func (n *node) load() error {
 		kvs[i].Value = sr.GetChildAddress(i)
 	}
 
-	n.KVS = kvs
+	numberOfNodeChildren := sr.NumberOfChildren() - len(kvs) - len(overflowKeys)
 
-	if sr.NumberOfChildren() == len(kvs) {
-		n.address = store.NilAddress
-		return nil
+	if numberOfNodeChildren != 0 && numberOfNodeChildren != len(kvs)+1 {
+		return errors.New("segment doesn't have enough children for child addresses")
 	}
 
-	if sr.NumberOfChildren() != len(kvs)*2+1 {
-		return errors.New("segment doesn't have enough children for child addresses")
+	overflowStart := len(kvs) + numberOfNodeChildren
+	for i, idx := range overflowKeys {
+		oa := sr.GetChildAddress(overflowStart + i)
+		k, err := readOverflowKey(n.reader, oa)
+		if err != nil {
+			return err
+		}
+		kvs[idx].Key = k
+		kvs[idx].overflow = oa
+	}
+
+	n.KVS = kvs
+	n.address = store.NilAddress
+
+	if numberOfNodeChildren == 0 {
+		return nil
 	}
 
 	children := []*node{}
@@ -137,7 +177,6 @@ func (n *node) load() error {
 	}
 
 	n.Children = children
-	n.address = store.NilAddress
 
 	return nil
 }
@@ -149,23 +188,49 @@ func (n *node) persist() (store.Address, error) {
 
 	dataSize := 8
 
-	for _, kv := range n.KVS {
-		dataSize += 2 + len(kv.Key)
+	overflow := []store.Address{}
+
+	for i, kv := range n.KVS {
+		if len(kv.Key) <= maxInlineKeySize {
+			dataSize += 2 + len(kv.Key)
+			continue
+		}
+
+		dataSize += 2
+
+		if kv.overflow == store.NilAddress {
+			oa, err := n.writeOverflowKey(kv.Key)
+			if err != nil {
+				return store.NilAddress, err
+			}
+			n.KVS[i].overflow = oa
+		}
+
+		overflow = append(overflow, n.KVS[i].overflow)
 	}
 
-	noc := len(n.KVS) + len(n.Children)
+	noc := len(n.KVS) + len(n.Children) + len(overflow)
 
 	sw, err := n.writer.AppendBlock(store.TypeBTreeNode, noc, dataSize)
 	if err != nil {
 		return store.NilAddress, errors.Wrap(err, "while creating a new segment")
 	}
 
+	if n.shared {
+		sw.SetShared()
+	}
+
 	d := sw.Data
 
 	binary.BigEndian.PutUint64(d, n.Count)
 	d = d[8:]
 
 	for _, kv := range n.KVS {
+		if len(kv.Key) > maxInlineKeySize {
+			binary.BigEndian.PutUint16(d, overflowKeyMarker)
+			d = d[2:]
+			continue
+		}
 		binary.BigEndian.PutUint16(d, uint16(len(kv.Key)))
 		d = d[2:]
 		copy(d, kv.Key)
@@ -184,6 +249,11 @@ func (n *node) persist() (store.Address, error) {
 		sw.SetChild(len(n.KVS)+i, addr)
 	}
 
+	overflowStart := len(n.KVS) + len(n.Children)
+	for i, oa := range overflow {
+		sw.SetChild(overflowStart+i, oa)
+	}
+
 	n.address = sw.Address
 
 	return n.address, nil
@@ -197,7 +267,20 @@ func (n *node) insert(kv keyValue) (ir insertResult, err error) {
 	}
 
 	if n.isFull() {
-		middleValue, left, right := n.split()
+		middleValue, left, right, err := n.split()
+		if err != nil {
+			return insertResult{}, err
+		}
+
+		if bytes.Equal(kv.Key, middleValue.Key) {
+			return insertResult{
+				DidSplit: true,
+				Left:     left,
+				Middle:   kv,
+				Right:    right,
+			}, nil
+		}
+
 		if bytes.Compare(kv.Key, middleValue.Key) < 0 {
 			ir, err = left.insert(kv)
 			if err != nil {
@@ -225,7 +308,7 @@ func (n *node) insert(kv keyValue) (ir insertResult, err error) {
 			return bytes.Compare(n.KVS[i].Key, kv.Key) >= 0
 		})
 		if idx < len(n.KVS) && bytes.Compare(n.KVS[idx].Key, kv.Key) == 0 {
-			n.KVS[idx] = kv
+			n.KVS[idx].Value = kv.Value
 			return insertResult{}, nil
 		}
 
@@ -254,7 +337,7 @@ func (n *node) insert(kv keyValue) (ir insertResult, err error) {
 	})
 
 	if idx < len(n.KVS) && bytes.Compare(n.KVS[idx].Key, kv.Key) == 0 {
-		n.KVS[idx] = kv
+		n.KVS[idx].Value = kv.Value
 		return insertResult{
 			DidInsert: false,
 		}, nil
@@ -268,7 +351,7 @@ func (n *node) insert(kv keyValue) (ir insertResult, err error) {
 	}, nil
 }
 
-func (n *node) split() (keyValue, *node, *node) {
+func (n *node) split() (keyValue, *node, *node, error) {
 	if !n.isFull() {
 		// TODO: remove me
 		panic("splitting a non-full node")
@@ -277,30 +360,50 @@ func (n *node) split() (keyValue, *node, *node) {
 	middleIdx := len(n.KVS) / 2
 
 	left := &node{
-		KVS:     n.KVS[:middleIdx],
-		Count:   n.Count / 2,
+		KVS:     append([]keyValue(nil), n.KVS[:middleIdx]...),
 		m:       n.m,
 		reader:  n.reader,
 		writer:  n.writer,
 		address: store.NilAddress,
+		shared:  n.shared,
 	}
 
 	right := &node{
-		KVS:     n.KVS[middleIdx+1:],
-		Count:   n.Count / 2,
+		KVS:     append([]keyValue(nil), n.KVS[middleIdx+1:]...),
 		m:       n.m,
 		reader:  n.reader,
 		writer:  n.writer,
 		address: store.NilAddress,
+		shared:  n.shared,
 	}
 
 	if !n.isLeaf() {
-		left.Children = n.Children[:middleIdx+1]
-		right.Children = n.Children[middleIdx+1:]
+		left.Children = append([]*node(nil), n.Children[:middleIdx+1]...)
+		right.Children = append([]*node(nil), n.Children[middleIdx+1:]...)
 	}
 
-	return n.KVS[middleIdx], left, right
+	for _, half := range []*node{left, right} {
+		half.Count = uint64(len(half.KVS))
+		for _, c := range half.Children {
+			cnt, err := c.subtreeCount()
+			if err != nil {
+				return keyValue{}, nil, nil, errors.Wrap(err, "while counting keys of a child")
+			}
+			half.Count += cnt
+		}
+	}
+
+	return n.KVS[middleIdx], left, right, nil
+
+}
 
+// subtreeCount returns number of keys in the node and all its children.
+// Nodes that have not been loaded are not loaded in order not to persist them again.
+func (n *node) subtreeCount() (uint64, error) {
+	if n.address == store.NilAddress {
+		return n.Count, nil
+	}
+	return Count(n.reader, n.address)
 }
 
 func insertIntoBtree(root *node, kv keyValue) (*node, error) {
@@ -328,6 +431,7 @@ func insertIntoBtree(root *node, kv keyValue) (*node, error) {
 		reader:  root.reader,
 		writer:  root.writer,
 		address: store.NilAddress,
+		shared:  root.shared,
 	}, nil
 
 }
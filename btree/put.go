@@ -7,7 +7,12 @@ import "github.com/draganm/chaintrackdb/store"
 const M = 1
 
 // Put creates a new BTree containing the given key/value
+// Returns ErrKeyTooLarge if the key is larger than MaxKeySize.
 func Put(rw store.ReaderWriter, root store.Address, key []byte, value store.Address) (store.Address, error) {
+	if len(key) > MaxKeySize {
+		return store.NilAddress, ErrKeyTooLarge
+	}
+
 	n := &node{
 		m:       M,
 		address: root,
@@ -15,7 +20,7 @@ func Put(rw store.ReaderWriter, root store.Address, key []byte, value store.Addr
 		writer:  rw,
 	}
 
-	rn, err := insertIntoBtree(n, keyValue{key, value})
+	rn, err := insertIntoBtree(n, keyValue{Key: key, Value: value})
 	if err != nil {
 		return store.NilAddress, err
 	}
@@ -108,3 +108,58 @@ func TestRandomInserts(t *testing.T) {
 		require.Equal(t, values[i], v)
 	}
 }
+
+func TestOverwritingKeys(t *testing.T) {
+
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	numberOfKeys := 256
+
+	a, err := btree.CreateEmpty(ts)
+	require.NoError(t, err)
+
+	keys := make([][]byte, numberOfKeys)
+
+	for i := range keys {
+		keys[i] = []byte{byte(i >> 8), byte(i)}
+		v, err := data.StoreData(ts, keys[i], 256, 4)
+		require.NoError(t, err)
+		a, err = btree.Put(ts, a, keys[i], v)
+		require.NoError(t, err)
+	}
+
+	values := make([]store.Address, numberOfKeys)
+
+	for _, i := range rand.Perm(numberOfKeys) {
+		v, err := data.StoreData(ts, []byte{1}, 256, 4)
+		require.NoError(t, err)
+		values[i] = v
+		a, err = btree.Put(ts, a, keys[i], v)
+		require.NoError(t, err)
+	}
+
+	cnt, err := btree.Count(ts, a)
+	require.NoError(t, err)
+	require.Equal(t, uint64(numberOfKeys), cnt)
+
+	for i, k := range keys {
+		v, err := btree.Get(ts, a, k)
+		require.NoError(t, err)
+		require.Equal(t, values[i], v)
+	}
+}
+
+func TestPutIntoNonBTreeNode(t *testing.T) {
+	ts, cleanup := btree.NewWriteTransaction(t)
+	defer cleanup()
+
+	v, err := data.StoreData(ts, []byte{1, 2, 3}, 256, 4)
+	require.NoError(t, err)
+
+	_, err = btree.Put(ts, v, []byte{1}, v)
+	require.Equal(t, btree.ErrNotBTreeNode, err)
+
+	_, err = btree.Get(ts, v, []byte{1})
+	require.Equal(t, btree.ErrNotBTreeNode, err)
+}
@@ -0,0 +1,24 @@
+package chaintrackdb
+
+import (
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/store"
+)
+
+// Check checks every block of the database in dir reachable from the last commited root,
+// including the contents of maps and values.
+// If repair is true, problems of block headers are fixed in place.
+// The database must not be opened during the check.
+func Check(dir string, repair bool) (store.Report, error) {
+	return store.CheckWithOptions(dir, store.CheckOptions{
+		Repair: repair,
+		Checkers: map[store.BlockType]store.BlockChecker{
+			store.TypeBTreeNode:  btree.CheckNode,
+			store.TypeDataLeaf:   data.CheckBlock,
+			store.TypeDataNode:   data.CheckBlock,
+			store.TypeTypedValue: data.CheckBlock,
+			store.TypeMergeNode:  data.CheckBlock,
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+
+	require.NoError(t, db.RegisterMergeOperator("counter", "counters", chaintrackdb.CounterAdd))
+
+	for i := 0; i < 30; i++ {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			for j := 0; j < 10; j++ {
+				err := tx.PutAll(fmt.Sprintf("a/%d/%d", i, j), make([]byte, i*1000))
+				if err != nil {
+					return err
+				}
+			}
+			err := tx.PutAll("long/"+strings.Repeat("k", 2000+i), []byte{1})
+			if err != nil {
+				return err
+			}
+			err = tx.CreateMapAll("b")
+			if err != nil {
+				return err
+			}
+			err = tx.CreateMapAll("counters")
+			if err != nil {
+				return err
+			}
+			err = tx.Merge("counters/c", make([]byte, 8))
+			if err != nil {
+				return err
+			}
+			return tx.Copy(fmt.Sprintf("a/%d", i), fmt.Sprintf("b/%d", i))
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, db.Compact())
+	require.NoError(t, db.Close())
+
+	t.Run("when I check the database", func(t *testing.T) {
+		report, err := chaintrackdb.Check(td, false)
+		require.NoError(t, err)
+
+		t.Run("then there should be no problems", func(t *testing.T) {
+			require.Empty(t, report.Problems)
+			require.True(t, report.OK())
+			require.NotZero(t, report.Blocks)
+		})
+	})
+}
@@ -0,0 +1,266 @@
+// Package client accesses a database served by the server package.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/server"
+	"github.com/pkg/errors"
+)
+
+var _ chaintrackdb.ReadWriter = (*WriteTransaction)(nil)
+
+// Client is a client of a database served by server.Server.
+type Client struct {
+	baseURL string
+	hc      *http.Client
+}
+
+// New creates a client of the server at baseURL.
+// http.DefaultClient is used if hc is nil.
+func New(baseURL string, hc *http.Client) *Client {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return &Client{baseURL: baseURL, hc: hc}
+}
+
+// WriteTransaction is a write transaction running on the server.
+// It has the same methods and semantics as chaintrackdb.WriteTransaction.
+type WriteTransaction struct {
+	c   *Client
+	ctx context.Context
+	id  string
+}
+
+// NewWriteTransaction starts a write transaction on the server.
+// The transaction is rolled back by the server if it is not used for
+// the server's TransactionTimeout.
+func (c *Client) NewWriteTransaction(ctx context.Context) (*WriteTransaction, error) {
+	res := server.TransactionResponse{}
+	err := c.do(ctx, http.MethodPost, "/transactions", nil, nil, &res)
+	if err != nil {
+		return nil, errors.Wrap(err, "while starting transaction")
+	}
+
+	return &WriteTransaction{c: c, ctx: ctx, id: res.ID}, nil
+}
+
+// WriteTransaction runs f in a new write transaction and commits it if f doesn't return an error.
+// ErrConflict is returned if the transaction could not be commited
+// because of a concurrent transaction, in which case f can be retried.
+func (c *Client) WriteTransaction(ctx context.Context, f func(tx *WriteTransaction) error) error {
+	tx, err := c.NewWriteTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+
+	if err != nil {
+		rbe := tx.Rollback()
+		if rbe != nil {
+			return errors.Wrap(err, "while rolling back transaction")
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Batch runs ops in one transaction on the server.
+// Either all operations succeed and are commited, or none of them.
+func (c *Client) Batch(ctx context.Context, ops []server.Op) ([]server.Result, error) {
+	res := server.BatchResponse{}
+	err := c.do(ctx, http.MethodPost, "/batch", nil, server.BatchRequest{Ops: ops}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Results, nil
+}
+
+// Do runs ops in the transaction, stopping at the first failing one.
+func (w *WriteTransaction) Do(ops []server.Op) ([]server.Result, error) {
+	res := server.BatchResponse{}
+	err := w.c.do(w.ctx, http.MethodPost, w.url("ops"), nil, server.BatchRequest{Ops: ops}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Results, nil
+}
+
+func (w *WriteTransaction) do(op, path string, value []byte) (server.Result, error) {
+	res, err := w.Do([]server.Op{{Op: op, Path: path, Value: value}})
+	if err != nil {
+		return server.Result{}, err
+	}
+	return res[0], nil
+}
+
+// Get reads the value at path.
+func (w *WriteTransaction) Get(path string) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	err := w.GetTo(path, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GetTo streams the value at path to dst.
+func (w *WriteTransaction) GetTo(path string, dst io.Writer) error {
+	return w.c.do(w.ctx, http.MethodGet, w.url("value"), url.Values{"path": {path}}, nil, dst)
+}
+
+// Put stores the value at path.
+func (w *WriteTransaction) Put(path string, d []byte) error {
+	return w.PutFrom(path, bytes.NewReader(d))
+}
+
+// PutAll puts the value creating all missing maps on the path.
+func (w *WriteTransaction) PutAll(path string, d []byte) error {
+	return w.c.do(w.ctx, http.MethodPut, w.url("value"), url.Values{"path": {path}, "all": {"true"}}, bytes.NewReader(d), nil)
+}
+
+// PutFrom streams the value to be stored at path from src.
+func (w *WriteTransaction) PutFrom(path string, src io.Reader) error {
+	return w.c.do(w.ctx, http.MethodPut, w.url("value"), url.Values{"path": {path}}, src, nil)
+}
+
+// CreateMap creates a map at path.
+func (w *WriteTransaction) CreateMap(path string) error {
+	_, err := w.do(server.OpCreateMap, path, nil)
+	return err
+}
+
+// CreateMapAll creates the map and all missing maps on the path.
+func (w *WriteTransaction) CreateMapAll(path string) error {
+	_, err := w.do(server.OpCreateMapAll, path, nil)
+	return err
+}
+
+// Delete removes the value or the map with all its contents stored at path.
+func (w *WriteTransaction) Delete(path string) error {
+	_, err := w.do(server.OpDelete, path, nil)
+	return err
+}
+
+// Exists returns true if there is a value or a map at path.
+func (w *WriteTransaction) Exists(path string) (bool, error) {
+	res, err := w.do(server.OpExists, path, nil)
+	return res.Exists, err
+}
+
+// Count returns the number of keys of the map at path.
+func (w *WriteTransaction) Count(path string) (uint64, error) {
+	res, err := w.do(server.OpCount, path, nil)
+	return res.Count, err
+}
+
+// ForEachKey calls f with every key of the map at path, in the key order.
+// All keys are read from the server before f is called.
+func (w *WriteTransaction) ForEachKey(path string, f func(key string) error) error {
+	res, err := w.do(server.OpKeys, path, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range res.Keys {
+		err = f(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Commit commits the transaction.
+func (w *WriteTransaction) Commit() error {
+	return w.c.do(w.ctx, http.MethodPost, w.url("commit"), nil, nil, nil)
+}
+
+// Rollback discards all changes made by the transaction.
+// Rolling back a transaction that has already been closed is a no-op.
+func (w *WriteTransaction) Rollback() error {
+	err := w.c.do(w.ctx, http.MethodPost, w.url("rollback"), nil, nil, nil)
+	if err == chaintrackdb.ErrTxClosed {
+		return nil
+	}
+	return err
+}
+
+func (w *WriteTransaction) url(endpoint string) string {
+	return "/transactions/" + url.PathEscape(w.id) + "/" + endpoint
+}
+
+// do sends a request and decodes the response into res.
+// body is sent as is if it is an io.Reader, JSON encoded otherwise.
+// The response body is copied into res if it is an io.Writer, JSON decoded otherwise.
+// Errors of the transaction are returned as the matching chaintrackdb errors.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, res interface{}) error {
+	var br io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		br = b
+	default:
+		d, err := json.Marshal(b)
+		if err != nil {
+			return errors.Wrap(err, "while encoding request")
+		}
+		br = bytes.NewReader(d)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, br)
+	if err != nil {
+		return errors.Wrap(err, "while creating request")
+	}
+
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "while sending %s %s", method, path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		er := server.ErrorResponse{}
+		err = json.NewDecoder(resp.Body).Decode(&er)
+		if err != nil {
+			return errors.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+
+		known := server.ErrorForCode(er.Code)
+		if known != nil {
+			return known
+		}
+
+		return errors.New(er.Message)
+	}
+
+	switch r := res.(type) {
+	case nil:
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+	case io.Writer:
+		_, err = io.Copy(r, resp.Body)
+	default:
+		err = json.NewDecoder(resp.Body).Decode(r)
+	}
+	if err != nil {
+		return errors.Wrap(err, "while reading response")
+	}
+
+	return nil
+}
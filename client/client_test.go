@@ -0,0 +1,225 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/client"
+	"github.com/draganm/chaintrackdb/server"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) (*client.Client, *server.Server, func()) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+
+	srv := server.New(db)
+	hs := httptest.NewServer(srv)
+
+	return client.New(hs.URL, hs.Client()), srv, func() {
+		hs.Close()
+		require.NoError(t, srv.Close())
+		require.NoError(t, db.Close())
+		require.NoError(t, os.RemoveAll(td))
+	}
+}
+
+func TestClient(t *testing.T) {
+	c, srv, cleanup := newServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I commit a transaction", func(t *testing.T) {
+		err := c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+			err := tx.CreateMap("m")
+			require.NoError(t, err)
+			err = tx.Put("m/a", []byte("abc"))
+			require.NoError(t, err)
+			return tx.PutAll("x/y/z", []byte{})
+		})
+		require.NoError(t, err)
+
+		t.Run("then the changes should be visible in a new transaction", func(t *testing.T) {
+			err = c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+				d, err := tx.Get("m/a")
+				require.NoError(t, err)
+				require.Equal(t, []byte("abc"), d)
+
+				d, err = tx.Get("x/y/z")
+				require.NoError(t, err)
+				require.Equal(t, []byte{}, d)
+
+				ex, err := tx.Exists("m/a")
+				require.NoError(t, err)
+				require.True(t, ex)
+
+				ex, err = tx.Exists("m/b")
+				require.NoError(t, err)
+				require.False(t, ex)
+
+				cnt, err := tx.Count("m")
+				require.NoError(t, err)
+				require.Equal(t, uint64(1), cnt)
+
+				keys := []string{}
+				err = tx.ForEachKey("", func(key string) error {
+					keys = append(keys, key)
+					return nil
+				})
+				require.NoError(t, err)
+				require.Equal(t, []string{"m", "x"}, keys)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then errors should be the chaintrackdb errors", func(t *testing.T) {
+			err = c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+				_, err := tx.Get("m/b")
+				require.Equal(t, chaintrackdb.ErrNotFound, err)
+
+				_, err = tx.Get("m")
+				require.Equal(t, chaintrackdb.ErrIsMap, err)
+
+				_, err = tx.Count("m/a")
+				require.Equal(t, chaintrackdb.ErrNotMap, err)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I stream a large value", func(t *testing.T) {
+		value := bytes.Repeat([]byte("0123456789"), 200000)
+		err := c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+			return tx.PutFrom("large", bytes.NewReader(value))
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should be able to stream it back", func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err = c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+				return tx.GetTo("large", buf)
+			})
+			require.NoError(t, err)
+			require.Equal(t, value, buf.Bytes())
+		})
+	})
+
+	t.Run("when two transactions change the same path", func(t *testing.T) {
+		tx1, err := c.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+		defer tx1.Rollback()
+
+		_, err = tx1.Get("m/a")
+		require.NoError(t, err)
+
+		err = c.WriteTransaction(ctx, func(tx *client.WriteTransaction) error {
+			return tx.Put("m/a", []byte("def"))
+		})
+		require.NoError(t, err)
+
+		err = tx1.Put("m/a", []byte("ghi"))
+		require.NoError(t, err)
+
+		t.Run("then commiting the later one should fail with ErrConflict", func(t *testing.T) {
+			err = tx1.Commit()
+			require.Equal(t, chaintrackdb.ErrConflict, err)
+		})
+
+		t.Run("then using the closed transaction should fail with ErrTxClosed", func(t *testing.T) {
+			err = tx1.Put("m/a", []byte("ghi"))
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+	})
+
+	t.Run("when I run a batch", func(t *testing.T) {
+		res, err := c.Batch(ctx, []server.Op{
+			{Op: server.OpCreateMapAll, Path: "b/c"},
+			{Op: server.OpPut, Path: "b/c/d", Value: []byte("v")},
+			{Op: server.OpGet, Path: "b/c/d"},
+			{Op: server.OpCount, Path: "b/c"},
+			{Op: server.OpExists, Path: "b/c/e"},
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should get the results of all operations", func(t *testing.T) {
+			require.Equal(t, []server.Result{
+				{},
+				{},
+				{Value: []byte("v")},
+				{Count: 1},
+				{Exists: false},
+			}, res)
+		})
+	})
+
+	t.Run("when an operation of a batch fails", func(t *testing.T) {
+		_, err := c.Batch(ctx, []server.Op{
+			{Op: server.OpPut, Path: "b/c/f", Value: []byte("v")},
+			{Op: server.OpDelete, Path: "b/c/nope"},
+		})
+
+		t.Run("then I should get the error", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrNotFound, err)
+		})
+
+		t.Run("then none of the operations should be commited", func(t *testing.T) {
+			res, err := c.Batch(ctx, []server.Op{{Op: server.OpExists, Path: "b/c/f"}})
+			require.NoError(t, err)
+			require.False(t, res[0].Exists)
+		})
+	})
+
+	t.Run("when a transaction is rolled back", func(t *testing.T) {
+		tx, err := c.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		res, err := tx.Do([]server.Op{
+			{Op: server.OpPut, Path: "rb", Value: []byte("v")},
+			{Op: server.OpExists, Path: "rb"},
+		})
+		require.NoError(t, err)
+		require.True(t, res[1].Exists)
+
+		err = tx.Rollback()
+		require.NoError(t, err)
+
+		t.Run("then the changes should be discarded", func(t *testing.T) {
+			res, err := c.Batch(ctx, []server.Op{{Op: server.OpExists, Path: "rb"}})
+			require.NoError(t, err)
+			require.False(t, res[0].Exists)
+		})
+
+		t.Run("then rolling back again should be a no-op", func(t *testing.T) {
+			require.NoError(t, tx.Rollback())
+		})
+	})
+
+	t.Run("when a transaction is not used for longer than the timeout", func(t *testing.T) {
+		srv.TransactionTimeout = 10 * time.Millisecond
+		defer func() {
+			srv.TransactionTimeout = server.DefaultTransactionTimeout
+		}()
+
+		tx, err := c.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		time.Sleep(50 * time.Millisecond)
+
+		t.Run("then it should be rolled back", func(t *testing.T) {
+			_, err = tx.Exists("m")
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+	})
+}
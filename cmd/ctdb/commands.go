@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/pkg/errors"
+)
+
+// parseArgs parses flags of a command and checks the number of the remaining arguments.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(ioutil.Discard)
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if fs.NArg() < min || fs.NArg() > max {
+		return nil, errors.Errorf("%s: wrong number of arguments", fs.Name())
+	}
+
+	return fs.Args(), nil
+}
+
+func ls(e env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("ls", flag.ContinueOnError), args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	path := ""
+	if len(args) == 1 {
+		path = args[0]
+	}
+
+	return e.db.ReadTransaction(context.Background(), func(tx *chaintrackdb.ReadTransaction) error {
+		tw := tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', 0)
+		err := tx.ForEachKey(path, func(key string) error {
+			st, err := tx.Stat(childPath(path, key))
+			if err != nil {
+				return errors.Wrapf(err, "while getting stat of %q", key)
+			}
+			_, err = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", kindOf(st), typeOf(st), st.Size, dbpath.EscapePart(key))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return tw.Flush()
+	})
+}
+
+// childPath returns the escaped path of the key in the map at path.
+func childPath(path, key string) string {
+	if strings.Trim(path, dbpath.Separator) == "" {
+		return dbpath.EscapePart(key)
+	}
+	return strings.TrimSuffix(path, dbpath.Separator) + dbpath.Separator + dbpath.EscapePart(key)
+}
+
+func kindOf(st chaintrackdb.Stat) string {
+	if st.Kind == chaintrackdb.KindMap {
+		return "map"
+	}
+	return "value"
+}
+
+func typeOf(st chaintrackdb.Stat) string {
+	if st.Kind == chaintrackdb.KindMap {
+		return "-"
+	}
+	return st.ValueType.String()
+}
+
+func get(e env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("get", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	return e.db.ReadTransaction(context.Background(), func(tx *chaintrackdb.ReadTransaction) error {
+		d, err := tx.Get(args[0])
+		if err != nil {
+			return err
+		}
+		_, err = e.stdout.Write(d)
+		return err
+	})
+}
+
+func put(e env, args []string) error {
+	fs := flag.NewFlagSet("put", flag.ContinueOnError)
+	parents := fs.Bool("p", false, "create missing maps")
+	args, err := parseArgs(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = e.stdin
+	if len(args) == 2 && args[1] != "-" {
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	d, err := ioutil.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, "while reading value")
+	}
+
+	return e.db.WriteTransaction(context.Background(), func(tx *chaintrackdb.WriteTransaction) error {
+		if *parents {
+			return tx.PutAll(args[0], d)
+		}
+		return tx.Put(args[0], d)
+	})
+}
+
+func mkdir(e env, args []string) error {
+	fs := flag.NewFlagSet("mkdir", flag.ContinueOnError)
+	parents := fs.Bool("p", false, "create missing maps, no error if the map exists")
+	args, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	return e.db.WriteTransaction(context.Background(), func(tx *chaintrackdb.WriteTransaction) error {
+		if *parents {
+			return tx.CreateMapAll(args[0])
+		}
+		return tx.CreateMap(args[0])
+	})
+}
+
+func rm(e env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("rm", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	return e.db.WriteTransaction(context.Background(), func(tx *chaintrackdb.WriteTransaction) error {
+		return tx.Delete(args[0])
+	})
+}
+
+func statCmd(e env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("stat", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	return e.db.ReadTransaction(context.Background(), func(tx *chaintrackdb.ReadTransaction) error {
+		st, err := tx.Stat(args[0])
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', 0)
+		fmt.Fprintf(tw, "kind:\t%s\n", kindOf(st))
+		fmt.Fprintf(tw, "type:\t%s\n", typeOf(st))
+		fmt.Fprintf(tw, "size:\t%d\n", st.Size)
+		fmt.Fprintf(tw, "address:\t%d\n", st.Address)
+		return tw.Flush()
+	})
+}
+
+func stats(e env, args []string) error {
+	_, err := parseArgs(flag.NewFlagSet("stats", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	st, err := e.db.Stats()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "root:\t%d\n", st.Root)
+	fmt.Fprintf(tw, "lowest address:\t%d\n", st.LowestAddress)
+	fmt.Fprintf(tw, "highest address:\t%d\n", st.HighestAddress)
+	fmt.Fprintf(tw, "occupied bytes:\t%d\n", st.OccupiedBytes)
+	fmt.Fprintf(tw, "used bytes:\t%d\n", st.UsedBytes)
+	fmt.Fprintf(tw, "live bytes:\t%d\n", st.LiveBytes)
+	if st.OccupiedBytes > 0 {
+		fmt.Fprintf(tw, "garbage:\t%.2f%%\n", float64(st.OccupiedBytes-st.LiveBytes)/float64(st.OccupiedBytes)*100)
+	}
+	return tw.Flush()
+}
+
+func compact(e env, args []string) error {
+	_, err := parseArgs(flag.NewFlagSet("compact", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	before, err := e.db.Stats()
+	if err != nil {
+		return err
+	}
+
+	err = e.db.Compact()
+	if err != nil {
+		return err
+	}
+
+	after, err := e.db.Stats()
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(e.stdout, "occupied bytes: %d -> %d\n", before.OccupiedBytes, after.OccupiedBytes)
+	return err
+}
+
+func check(e env, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "fix block headers in place")
+	_, err := parseArgs(fs, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	if *repair && e.readOnly {
+		return errors.New("check -repair can't be used with --read-only")
+	}
+
+	report, err := chaintrackdb.Check(e.dir, *repair)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range report.Problems {
+		fmt.Fprintln(e.stdout, p)
+	}
+
+	_, err = fmt.Fprintf(e.stdout, "checked %d blocks (%d bytes) reachable from %d, %d problems\n", report.Blocks, report.Bytes, report.Root, len(report.Problems))
+	if err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return errors.New("database is not consistent")
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/draganm/chaintrackdb/store/inspect"
+	"github.com/pkg/errors"
+)
+
+func debug(e env, args []string) error {
+	if len(args) == 0 {
+		return errors.New("debug: subcommand is missing")
+	}
+
+	switch args[0] {
+	case "segments":
+		return debugSegments(e, args[1:])
+	case "block":
+		return debugBlock(e, args[1:])
+	case "dot":
+		return debugDOT(e, args[1:])
+	case "lda":
+		return debugLDA(e, args[1:])
+	default:
+		return errors.Errorf("debug: unknown subcommand %q", args[0])
+	}
+}
+
+func parseAddress(s string) (store.Address, error) {
+	a, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return store.NilAddress, errors.Wrapf(err, "while parsing address %q", s)
+	}
+	return store.Address(a), nil
+}
+
+func debugSegments(e env, args []string) error {
+	_, err := parseArgs(flag.NewFlagSet("segments", flag.ContinueOnError), args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTART\tEND\tBYTES")
+	for _, s := range e.store.Segments() {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\n", s.Name, s.StartAddress, s.EndAddress, s.EndAddress-s.StartAddress)
+	}
+	fmt.Fprintf(tw, "root: %d\n", e.store.LastCommitAddress())
+	return tw.Flush()
+}
+
+func debugBlock(e env, args []string) error {
+	args, err := parseArgs(flag.NewFlagSet("block", flag.ContinueOnError), args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	a := e.store.LastCommitAddress()
+	if len(args) == 1 {
+		a, err = parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	return inspect.DumpBlock(e.stdout, e.store, a)
+}
+
+func debugDOT(e env, args []string) error {
+	fs := flag.NewFlagSet("dot", flag.ContinueOnError)
+	depth := fs.Int("depth", -1, "maximal depth of the graph, unlimited if negative")
+	args, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	a := e.store.LastCommitAddress()
+	if len(args) == 1 {
+		a, err = parseAddress(args[0])
+		if err != nil {
+			return err
+		}
+	}
+
+	return inspect.WriteDOT(e.stdout, e.store, a, *depth)
+}
+
+func debugLDA(e env, args []string) error {
+	fs := flag.NewFlagSet("lda", flag.ContinueOnError)
+	buckets := fs.Int("buckets", 10, "number of address ranges")
+	_, err := parseArgs(fs, args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	bs, err := inspect.LowestDescendantDistribution(e.store, e.store.LastCommitAddress(), *buckets)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "FROM\tTO\tBLOCKS\tBYTES")
+	for _, b := range bs {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\n", b.From, b.To, b.Blocks, b.Bytes)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,145 @@
+// Command ctdb inspects and edits a chaintrackdb database.
+//
+// Usage:
+//
+//	ctdb [--read-only] --db <dir> <command> [arguments]
+//
+// Paths are escaped dbpath paths, as accepted by dbpath.Split.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ctdb:", err)
+		os.Exit(1)
+	}
+}
+
+// env is what a command runs with.
+type env struct {
+	dir string
+	// readOnly is set for commands opening the database directory on their own.
+	readOnly bool
+	db       *chaintrackdb.DB
+	store    *store.Store
+	stdin    io.Reader
+	stdout   io.Writer
+}
+
+// openMode is what is opened before running a command.
+type openMode int
+
+const (
+	openDB openMode = iota
+	// openStore opens the store read only, for commands working with blocks.
+	openStore
+	// openNothing is for commands opening the database directory on their own.
+	openNothing
+)
+
+type command struct {
+	usage string
+	run   func(e env, args []string) error
+	open  openMode
+}
+
+var commands = map[string]command{
+	"ls":      {"ls [path]\tlist keys of a map", ls, openDB},
+	"get":     {"get <path>\twrite the value to stdout", get, openDB},
+	"put":     {"put [-p] <path> [file|-]\tstore the content of the file or stdin", put, openDB},
+	"mkdir":   {"mkdir [-p] <path>\tcreate a map", mkdir, openDB},
+	"rm":      {"rm <path>\tdelete a value or a map with all its contents", rm, openDB},
+	"stat":    {"stat <path>\tdescribe a value or a map", statCmd, openDB},
+	"stats":   {"stats\tshow space usage", stats, openDB},
+	"compact": {"compact\tremove garbage", compact, openDB},
+	"check":   {"check [-repair]\tcheck integrity of all blocks, the database must not be in use", check, openNothing},
+	"debug":   {"debug <segments|block|dot|lda> [arguments]\tinspect blocks of the store", debug, openStore},
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("ctdb", flag.ContinueOnError)
+	dir := fs.String("db", os.Getenv("CTDB_DB"), "database directory, defaults to $CTDB_DB")
+	readOnly := fs.Bool("read-only", false, "open the database without modifying it")
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "usage: ctdb [--read-only] --db <dir> <command> [arguments]")
+		fs.PrintDefaults()
+		fmt.Fprintln(out, "commands:")
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			fmt.Fprintln(out, "  "+strings.Replace(commands[n].usage, "\t", "\n    \t", 1))
+		}
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("command is missing")
+	}
+
+	cmd, found := commands[fs.Arg(0)]
+	if !found {
+		return errors.Errorf("unknown command %q", fs.Arg(0))
+	}
+
+	if *dir == "" {
+		return errors.New("--db is not set")
+	}
+
+	if cmd.open == openNothing {
+		return cmd.run(env{dir: *dir, readOnly: *readOnly, stdin: stdin, stdout: stdout}, fs.Args()[1:])
+	}
+
+	if cmd.open == openStore {
+		st, err := store.OpenReadOnly(*dir)
+		if err != nil {
+			return err
+		}
+
+		err = cmd.run(env{dir: *dir, store: st, stdin: stdin, stdout: stdout}, fs.Args()[1:])
+		cerr := st.Close()
+		if err != nil {
+			return err
+		}
+		return cerr
+	}
+
+	var db *chaintrackdb.DB
+	if *readOnly {
+		db, err = chaintrackdb.OpenReadOnly(*dir)
+	} else {
+		db, err = chaintrackdb.Open(*dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = cmd.run(env{dir: *dir, db: db, stdin: stdin, stdout: stdout}, fs.Args()[1:])
+	cerr := db.Close()
+	if err != nil {
+		return err
+	}
+
+	return cerr
+}
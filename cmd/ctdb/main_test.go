@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommands(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	ctdb := func(stdin string, args ...string) (string, error) {
+		out := new(bytes.Buffer)
+		err := run(append([]string{"--db", td}, args...), strings.NewReader(stdin), out)
+		return out.String(), err
+	}
+
+	t.Run("when I create maps and put a value", func(t *testing.T) {
+		_, err := ctdb("", "mkdir", "-p", "a/b")
+		require.NoError(t, err)
+		_, err = ctdb("hello", "put", "a/b/c", "-")
+		require.NoError(t, err)
+		_, err = ctdb("world", "put", "-p", "a/x%20y/z")
+		require.NoError(t, err)
+
+		t.Run("then get should write the value", func(t *testing.T) {
+			out, err := ctdb("", "get", "a/b/c")
+			require.NoError(t, err)
+			require.Equal(t, "hello", out)
+		})
+
+		t.Run("then ls should list the keys", func(t *testing.T) {
+			out, err := ctdb("", "ls", "a")
+			require.NoError(t, err)
+			require.Equal(t, "map - 1 b\nmap - 1 x%20y\n", out)
+		})
+
+		t.Run("then stat should describe the value", func(t *testing.T) {
+			out, err := ctdb("", "stat", "a/b/c")
+			require.NoError(t, err)
+			require.Contains(t, out, "kind:    value")
+			require.Contains(t, out, "size:    5")
+		})
+
+		t.Run("then check should find no problems", func(t *testing.T) {
+			out, err := ctdb("", "check")
+			require.NoError(t, err)
+			require.Contains(t, out, ", 0 problems\n")
+		})
+	})
+
+	t.Run("when I remove a value and compact", func(t *testing.T) {
+		_, err := ctdb("", "rm", "a/b/c")
+		require.NoError(t, err)
+		_, err = ctdb("", "compact")
+		require.NoError(t, err)
+
+		t.Run("then the value should not exist", func(t *testing.T) {
+			_, err := ctdb("", "get", "a/b/c")
+			require.Equal(t, chaintrackdb.ErrNotFound, errors.Cause(err))
+		})
+	})
+
+	t.Run("when I open the database read only", func(t *testing.T) {
+		t.Run("then reading should work", func(t *testing.T) {
+			out, err := ctdb("", "--read-only", "get", "a/x%20y/z")
+			require.NoError(t, err)
+			require.Equal(t, "world", out)
+		})
+
+		t.Run("then writing should fail", func(t *testing.T) {
+			_, err := ctdb("", "--read-only", "rm", "a/x%20y/z")
+			require.Equal(t, chaintrackdb.ErrReadOnly, errors.Cause(err))
+		})
+
+		t.Run("then check should work", func(t *testing.T) {
+			out, err := ctdb("", "--read-only", "check")
+			require.NoError(t, err)
+			require.Contains(t, out, ", 0 problems\n")
+		})
+
+		t.Run("then check with repair should fail", func(t *testing.T) {
+			_, err := ctdb("", "--read-only", "check", "-repair")
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("when I use debug subcommands", func(t *testing.T) {
+		t.Run("then segments should list the segment files", func(t *testing.T) {
+			out, err := ctdb("", "debug", "segments")
+			require.NoError(t, err)
+			require.Contains(t, out, "segment-")
+		})
+
+		t.Run("then block should dump the root block", func(t *testing.T) {
+			out, err := ctdb("", "debug", "block")
+			require.NoError(t, err)
+			require.Contains(t, out, "BTreeNode")
+		})
+
+		t.Run("then dot should write a graph", func(t *testing.T) {
+			out, err := ctdb("", "debug", "dot", "-depth", "1")
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(out, "digraph blocks {"))
+		})
+
+		t.Run("then lda should write the distribution", func(t *testing.T) {
+			out, err := ctdb("", "debug", "lda", "-buckets", "3")
+			require.NoError(t, err)
+			require.Equal(t, 4, strings.Count(out, "\n"))
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package chaintrackdb
+
+import (
+	"github.com/draganm/chaintrackdb/store"
+)
+
+// ErrConflict is returned when a transaction could not be commited because
+// a concurrently commited transaction has changed paths it depends on.
+// The transaction can be retried.
+var ErrConflict = store.ErrConflict
+
+// commitLogEntry records the paths written by a commited transaction.
+type commitLogEntry struct {
+	root  store.Address
+	paths [][]string
+}
+
+func (d *DB) commit(w *WriteTransaction) error {
+	d.commitMu.Lock()
+	defer d.commitMu.Unlock()
+
+	// changes made without maintaining a newly created index would make it inconsistent
+	d.indexMu.RLock()
+	indexesChanged := d.indexGeneration != w.indexGeneration
+	d.indexMu.RUnlock()
+
+	if indexesChanged && len(w.writes) > 0 {
+		rbe := w.swt.Rollback()
+		if rbe != nil {
+			return rbe
+		}
+		return ErrConflict
+	}
+
+	newRoot, err := w.swt.CommitFunc(func(base, latest store.Address) (store.Address, error) {
+		if base == latest {
+			return w.root, nil
+		}
+
+		if d.hasConflict(w, base) {
+			return store.NilAddress, ErrConflict
+		}
+
+		return w.rebase(latest)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(w.writes) > 0 {
+		paths := make([][]string, len(w.writes))
+		for i, wr := range w.writes {
+			paths[i] = wr.path
+		}
+		d.commitLog = append(d.commitLog, commitLogEntry{root: newRoot, paths: paths})
+	}
+
+	d.pruneCommitLog()
+
+	return nil
+}
+
+// hasConflict checks if any of the transactions commited after base has
+// written a path that was read or written by w.
+// Roots of commited transactions are always increasing, so every entry
+// with a root higher than base has been commited after w was started.
+func (d *DB) hasConflict(w *WriteTransaction, base store.Address) bool {
+	for _, e := range d.commitLog {
+		if e.root <= base {
+			continue
+		}
+		for _, wp := range e.paths {
+			for _, rp := range w.reads {
+				if pathsOverlap(wp, rp) {
+					return true
+				}
+			}
+			for _, wr := range w.writes {
+				// merges are re-applied onto the latest value
+				if wr.merge {
+					continue
+				}
+				if pathsOverlap(wp, wr.path) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pruneCommitLog removes entries that can't conflict with any of the
+// transactions in progress.
+func (d *DB) pruneCommitLog() {
+	oldest, found := d.s.OldestActiveRoot()
+	if !found {
+		d.commitLog = nil
+		return
+	}
+
+	for len(d.commitLog) > 0 && d.commitLog[0].root <= oldest {
+		d.commitLog = d.commitLog[1:]
+	}
+}
+
+// pathsOverlap returns true if one of the paths is a prefix of the other one.
+func pathsOverlap(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i, p := range a {
+		if b[i] != p {
+			return false
+		}
+	}
+	return true
+}
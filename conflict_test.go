@@ -0,0 +1,195 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentWriteTransactions(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I start two transactions writing different paths", func(t *testing.T) {
+		tx1, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		tx2, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx1.Put("abc", []byte{1})
+		require.NoError(t, err)
+
+		err = tx2.Put("def", []byte{2})
+		require.NoError(t, err)
+
+		t.Run("then both transactions should commit", func(t *testing.T) {
+			err = tx1.Commit()
+			require.NoError(t, err)
+
+			err = tx2.Commit()
+			require.NoError(t, err)
+		})
+
+		t.Run("then both values should exist", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("abc")
+				require.NoError(t, err)
+				require.Equal(t, []byte{1}, d)
+
+				d, err = tx.Get("def")
+				require.NoError(t, err)
+				require.Equal(t, []byte{2}, d)
+
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I start two transactions writing the same path", func(t *testing.T) {
+		tx1, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		tx2, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx1.Put("abc", []byte{3})
+		require.NoError(t, err)
+
+		err = tx2.Put("abc", []byte{4})
+		require.NoError(t, err)
+
+		t.Run("then the second commit should return conflict", func(t *testing.T) {
+			err = tx1.Commit()
+			require.NoError(t, err)
+
+			err = tx2.Commit()
+			require.Equal(t, chaintrackdb.ErrConflict, err)
+		})
+
+		t.Run("then the value of the first transaction should be kept", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("abc")
+				require.NoError(t, err)
+				require.Equal(t, []byte{3}, d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when a transaction counts a map another transaction has changed", func(t *testing.T) {
+		tx1, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		tx2, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		cnt, err := tx1.Count("/")
+		require.NoError(t, err)
+
+		err = tx1.Put("count", []byte{byte(cnt)})
+		require.NoError(t, err)
+
+		err = tx2.Put("ghi", []byte{5})
+		require.NoError(t, err)
+
+		t.Run("then the reading transaction should return conflict", func(t *testing.T) {
+			err = tx2.Commit()
+			require.NoError(t, err)
+
+			err = tx1.Commit()
+			require.Equal(t, chaintrackdb.ErrConflict, err)
+		})
+	})
+
+}
+
+func TestConcurrentWritersWithRetries(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.CreateMap("counters")
+		if err != nil {
+			return err
+		}
+		return tx.Put("total", make([]byte, 8))
+	})
+	require.NoError(t, err)
+
+	increment := func(tx *chaintrackdb.WriteTransaction, path string) error {
+		d, err := tx.Get(path)
+		if err == chaintrackdb.ErrNotFound {
+			d = make([]byte, 8)
+		} else if err != nil {
+			return err
+		}
+		nd := make([]byte, 8)
+		binary.BigEndian.PutUint64(nd, binary.BigEndian.Uint64(d)+1)
+		return tx.Put(path, nd)
+	}
+
+	writers := 8
+	incrementsPerWriter := 20
+
+	wg := new(sync.WaitGroup)
+	errs := make(chan error, writers)
+
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < incrementsPerWriter; j++ {
+				for {
+					err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+						err := increment(tx, fmt.Sprintf("counters/%d", i))
+						if err != nil {
+							return err
+						}
+						return increment(tx, "total")
+					})
+					if err == chaintrackdb.ErrConflict {
+						continue
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+					break
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		d, err := tx.Get("total")
+		require.NoError(t, err)
+		require.Equal(t, uint64(writers*incrementsPerWriter), binary.BigEndian.Uint64(d))
+
+		for i := 0; i < writers; i++ {
+			d, err = tx.Get(fmt.Sprintf("counters/%d", i))
+			require.NoError(t, err)
+			require.Equal(t, uint64(incrementsPerWriter), binary.BigEndian.Uint64(d))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,98 @@
+package chaintrackdb
+
+import (
+	serrors "errors"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrMoveIntoItself is returned when moving a map into its own subtree.
+var ErrMoveIntoItself = serrors.New("can't move a map into itself")
+
+// Copy stores the value or the map at src under dst.
+// No data is copied, src and dst share the same blocks.
+func (w *WriteTransaction) Copy(src, dst string) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	srcPath, err := dbpath.Split(src)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", src)
+	}
+
+	dstPath, err := dbpath.Split(dst)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", dst)
+	}
+
+	err = checkKeySizes(dstPath)
+	if err != nil {
+		return err
+	}
+
+	w.reads = append(w.reads, srcPath)
+
+	return w.withIndexes(dstPath, func() error {
+		return w.copy(srcPath, dstPath)
+	})
+}
+
+func (w *WriteTransaction) copy(srcPath, dstPath []string) error {
+	return w.applyWrite(write{
+		path: dstPath,
+		apply: func(root store.Address) (store.Address, error) {
+			// src is resolved on every apply, so a rebased transaction copies the latest src
+			sa, err := pathElementAddress(w.swt, root, srcPath)
+			if err != nil {
+				return store.NilAddress, err
+			}
+
+			// src and dst must both reference the shared block
+			// for the live size to be calculated correctly
+			sa, err = store.Share(w.swt, sa)
+			if err != nil {
+				return store.NilAddress, errors.Wrap(err, "while sharing src")
+			}
+
+			put := func(ad store.Address, key string) (store.Address, error) {
+				return btree.Put(w.swt, ad, []byte(key), sa)
+			}
+
+			root, err = modifyPath(w.swt, root, srcPath, false, put)
+			if err != nil {
+				return store.NilAddress, err
+			}
+
+			return modifyPath(w.swt, root, dstPath, false, put)
+		},
+	})
+}
+
+// Move moves the value or the map at src to dst.
+func (w *WriteTransaction) Move(src, dst string) error {
+	srcPath, err := dbpath.Split(src)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", src)
+	}
+
+	dstPath, err := dbpath.Split(dst)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", dst)
+	}
+
+	if len(srcPath) <= len(dstPath) && pathsOverlap(srcPath, dstPath) {
+		return ErrMoveIntoItself
+	}
+
+	err = w.Copy(src, dst)
+	if err != nil {
+		return err
+	}
+
+	return w.Delete(src)
+}
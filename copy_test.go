@@ -0,0 +1,146 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyAndMove(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.CreateMapAll("a/b")
+		require.NoError(t, err)
+		for _, k := range []string{"a/b/c", "a/b/d", "a/e"} {
+			err = tx.Put(k, []byte(k))
+			require.NoError(t, err)
+		}
+		return tx.CreateMap("x")
+	})
+	require.NoError(t, err)
+
+	t.Run("when I copy a map", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Copy("a/b", "x/y")
+		})
+		require.NoError(t, err)
+
+		t.Run("then the copy should share the blocks with the original", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				src, err := tx.Stat("a/b")
+				require.NoError(t, err)
+				dst, err := tx.Stat("x/y")
+				require.NoError(t, err)
+				require.Equal(t, src, dst)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("when I change the copy", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				err := tx.Put("x/y/c", []byte{1})
+				require.NoError(t, err)
+				return tx.Delete("x/y/d")
+			})
+			require.NoError(t, err)
+
+			t.Run("then the original should not change", func(t *testing.T) {
+				err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					d, err := tx.Get("a/b/c")
+					require.NoError(t, err)
+					require.Equal(t, []byte("a/b/c"), d)
+
+					d, err = tx.Get("a/b/d")
+					require.NoError(t, err)
+					require.Equal(t, []byte("a/b/d"), d)
+
+					d, err = tx.Get("x/y/c")
+					require.NoError(t, err)
+					require.Equal(t, []byte{1}, d)
+
+					_, err = tx.Get("x/y/d")
+					require.Equal(t, chaintrackdb.ErrNotFound, err)
+					return nil
+				})
+				require.NoError(t, err)
+			})
+		})
+	})
+
+	t.Run("when I copy a path that does not exist", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Copy("does/not/exist", "x/z")
+		})
+		t.Run("then I should get ErrNotFound", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrNotFound, errors.Cause(err))
+		})
+	})
+
+	t.Run("when I move a value", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Move("a/e", "x/e")
+		})
+		require.NoError(t, err)
+
+		t.Run("then the value should be only at the new path", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("x/e")
+				require.NoError(t, err)
+				require.Equal(t, []byte("a/e"), d)
+
+				ex, err := tx.Exists("a/e")
+				require.NoError(t, err)
+				require.False(t, ex)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I move a map into itself", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Move("a", "a/b/a")
+		})
+		t.Run("then I should get ErrMoveIntoItself", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrMoveIntoItself, err)
+		})
+	})
+
+	t.Run("when I delete a map", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Delete("a")
+		})
+		require.NoError(t, err)
+
+		t.Run("then the map and its copy should be handled independently", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				ex, err := tx.Exists("a")
+				require.NoError(t, err)
+				require.False(t, ex)
+
+				cnt, err := tx.Count("x/y")
+				require.NoError(t, err)
+				require.Equal(t, uint64(1), cnt)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I delete a path that does not exist", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Delete("nope")
+		})
+		t.Run("then I should get ErrNotFound", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrNotFound, errors.Cause(err))
+		})
+	})
+}
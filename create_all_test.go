@@ -0,0 +1,95 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatingMissingParents(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I create a map with missing parents", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.CreateMapAll("a/b/c")
+		})
+		require.NoError(t, err)
+
+		t.Run("then all maps should exist", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				for _, p := range []string{"a", "a/b", "a/b/c"} {
+					st, err := tx.Stat(p)
+					require.NoError(t, err)
+					require.Equal(t, chaintrackdb.KindMap, st.Kind)
+				}
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("and I put a value into the map", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("a/b/c/d", []byte{1})
+			})
+			require.NoError(t, err)
+
+			t.Run("then creating the map again should keep the value", func(t *testing.T) {
+				err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					err := tx.CreateMapAll("a/b/c")
+					require.NoError(t, err)
+
+					d, err := tx.Get("a/b/c/d")
+					require.NoError(t, err)
+					require.Equal(t, []byte{1}, d)
+					return nil
+				})
+				require.NoError(t, err)
+			})
+
+			t.Run("then creating a map below the value should fail", func(t *testing.T) {
+				err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					return tx.CreateMapAll("a/b/c/d/e")
+				})
+				require.Equal(t, chaintrackdb.ErrNotMap, errors.Cause(err))
+			})
+
+			t.Run("then creating a map in place of the value should fail", func(t *testing.T) {
+				err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					return tx.CreateMapAll("a/b/c/d")
+				})
+				require.Equal(t, chaintrackdb.ErrNotMap, errors.Cause(err))
+			})
+		})
+	})
+
+	t.Run("when I put a value with missing parents", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.PutAll("x/y/z", []byte{2})
+		})
+		require.NoError(t, err)
+
+		t.Run("then the value should exist", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("x/y/z")
+				require.NoError(t, err)
+				require.Equal(t, []byte{2}, d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then putting below the value should fail", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.PutAll("x/y/z/w", []byte{2})
+			})
+			require.Equal(t, chaintrackdb.ErrNotMap, errors.Cause(err))
+		})
+	})
+
+}
@@ -0,0 +1,101 @@
+package data
+
+import (
+	"encoding/binary"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// CheckBlock validates data, typed value and merge node blocks.
+// Size of a data node must be the sum of the sizes of its children.
+// It can be used as a store.BlockChecker.
+func CheckBlock(r store.Reader, a store.Address, br store.BlockReader) error {
+	switch br.Type() {
+	case store.TypeDataLeaf:
+		if br.NumberOfChildren() != 0 {
+			return errors.New("data leaf has children")
+		}
+		return nil
+
+	case store.TypeDataNode:
+		d := br.GetData()
+		if len(d) != 8 {
+			return errors.Errorf("data node has %d bytes of data, should be 8", len(d))
+		}
+
+		if br.NumberOfChildren() == 0 {
+			return errors.New("data node has no children")
+		}
+
+		var total uint64
+		for i := 0; i < br.NumberOfChildren(); i++ {
+			cbr, err := r.GetBlock(br.GetChildAddress(i))
+			if err != nil {
+				return errors.Wrapf(err, "while reading child %d", i)
+			}
+			if cbr.Type() != store.TypeDataLeaf && cbr.Type() != store.TypeDataNode {
+				return errors.Errorf("child %d is %s", i, cbr.Type())
+			}
+			size, err := Size(r, br.GetChildAddress(i))
+			if err != nil {
+				return errors.Wrapf(err, "while getting size of child %d", i)
+			}
+			total += size
+		}
+
+		size := binary.BigEndian.Uint64(d)
+		if size != total {
+			return errors.Errorf("data node size is %d, should be %d", size, total)
+		}
+		return nil
+
+	case store.TypeTypedValue:
+		_, da, err := ValueType(r, a)
+		if err != nil {
+			return err
+		}
+		return checkIsData(r, da, "value")
+
+	case store.TypeMergeNode:
+		_, base, operands, err := MergeNode(r, a)
+		if err != nil {
+			return err
+		}
+		if base != store.NilAddress {
+			bbr, err := r.GetBlock(base)
+			if err != nil {
+				return errors.Wrap(err, "while reading base")
+			}
+			if bbr.Type() != store.TypeMergeNode {
+				err = checkIsData(r, base, "base")
+				if err != nil {
+					return err
+				}
+			}
+		}
+		for _, o := range operands {
+			err = checkIsData(r, o, "operand")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return errors.Errorf("%s is not a data block", br.Type())
+	}
+}
+
+func checkIsData(r store.Reader, a store.Address, what string) error {
+	br, err := r.GetBlock(a)
+	if err != nil {
+		return errors.Wrapf(err, "while reading %s", what)
+	}
+	switch br.Type() {
+	case store.TypeDataLeaf, store.TypeDataNode, store.TypeTypedValue:
+		return nil
+	default:
+		return errors.Errorf("%s is %s, not data", what, br.Type())
+	}
+}
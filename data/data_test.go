@@ -216,3 +216,47 @@ func TestStore(t *testing.T) {
 	})
 
 }
+
+func TestTypedData(t *testing.T) {
+	st, cleanup := newWriteTransaction(t)
+	defer cleanup()
+
+	t.Run("when I store typed data", func(t *testing.T) {
+		k, err := data.StoreTypedData(st, 3, []byte{1, 2, 3}, 5, 2)
+		require.NoError(t, err)
+
+		t.Run("then the type and data should be returned", func(t *testing.T) {
+			vt, da, err := data.ValueType(st, k)
+			require.NoError(t, err)
+			require.Equal(t, byte(3), vt)
+
+			r, err := data.NewReader(da, st)
+			require.NoError(t, err)
+			d, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.Equal(t, []byte{1, 2, 3}, d)
+		})
+	})
+
+	t.Run("when I store untyped data", func(t *testing.T) {
+		k, err := data.StoreData(st, []byte{1, 2, 3}, 5, 2)
+		require.NoError(t, err)
+
+		t.Run("then the type should be 0", func(t *testing.T) {
+			vt, da, err := data.ValueType(st, k)
+			require.NoError(t, err)
+			require.Equal(t, byte(0), vt)
+			require.Equal(t, k, da)
+		})
+	})
+
+	t.Run("when I get the type of a non data block", func(t *testing.T) {
+		bw, err := st.AppendBlock(store.TypeBTreeNode, 0, 8)
+		require.NoError(t, err)
+
+		t.Run("then ErrNotData should be returned", func(t *testing.T) {
+			_, _, err := data.ValueType(st, bw.Address)
+			require.Equal(t, data.ErrNotData, err)
+		})
+	})
+}
@@ -0,0 +1,68 @@
+package data
+
+import (
+	serrors "errors"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrNotMergeNode is returned when the block at the given address is not a merge node.
+var ErrNotMergeNode = serrors.New("not a merge node")
+
+// MaxMergeOperands is the maximal number of operands of a merge node.
+const MaxMergeOperands = 254
+
+// StoreMergeNode stores a merge node of the operator with the given name.
+// The first child of a merge node is the base value, which can be NilAddress,
+// followed by the operands in the order they were merged.
+func StoreMergeNode(st store.ReaderWriter, operator string, base store.Address, operands []store.Address) (store.Address, error) {
+	if len(operands) > MaxMergeOperands {
+		return store.NilAddress, errors.New("too many merge operands")
+	}
+
+	bw, err := st.AppendBlock(store.TypeMergeNode, len(operands)+1, len(operator))
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while creating merge node")
+	}
+
+	copy(bw.Data, operator)
+
+	err = bw.SetChild(0, base)
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while setting base of merge node")
+	}
+
+	for i, o := range operands {
+		err = bw.SetChild(i+1, o)
+		if err != nil {
+			return store.NilAddress, errors.Wrap(err, "while setting operand of merge node")
+		}
+	}
+
+	return bw.Address, nil
+}
+
+// MergeNode returns the operator name, the base value and the operands of the merge node at addr.
+// Returns ErrNotMergeNode if the block at addr is not a merge node.
+func MergeNode(r store.Reader, addr store.Address) (string, store.Address, []store.Address, error) {
+	br, err := r.GetBlock(addr)
+	if err != nil {
+		return "", store.NilAddress, nil, err
+	}
+
+	if br.Type() != store.TypeMergeNode {
+		return "", store.NilAddress, nil, ErrNotMergeNode
+	}
+
+	if br.NumberOfChildren() < 1 {
+		return "", store.NilAddress, nil, errors.New("malformed merge node")
+	}
+
+	operands := make([]store.Address, br.NumberOfChildren()-1)
+	for i := range operands {
+		operands[i] = br.GetChildAddress(i + 1)
+	}
+
+	return string(br.GetData()), br.GetChildAddress(0), operands, nil
+}
@@ -0,0 +1,55 @@
+package data
+
+import (
+	serrors "errors"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrNotData is returned when the block at the given address is not a data block.
+var ErrNotData = serrors.New("not a data block")
+
+// StoreTypedData stores data wrapped in a TypedValue block recording the type of the value.
+func StoreTypedData(st store.ReaderWriter, valueType byte, data []byte, segSize, fanout int) (store.Address, error) {
+	dataAddress, err := StoreData(st, data, segSize, fanout)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	bw, err := st.AppendBlock(store.TypeTypedValue, 1, 1)
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while creating typed value block")
+	}
+
+	err = bw.SetChild(0, dataAddress)
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while setting data of typed value block")
+	}
+
+	bw.Data[0] = valueType
+
+	return bw.Address, nil
+}
+
+// ValueType returns the type of the value stored at addr and the address of its data.
+// Data stored without a type has type 0.
+// Returns ErrNotData if there is no data stored at addr.
+func ValueType(r store.Reader, addr store.Address) (byte, store.Address, error) {
+	br, err := r.GetBlock(addr)
+	if err != nil {
+		return 0, store.NilAddress, err
+	}
+
+	switch br.Type() {
+	case store.TypeDataLeaf, store.TypeDataNode:
+		return 0, addr, nil
+	case store.TypeTypedValue:
+		if br.NumberOfChildren() != 1 || len(br.GetData()) != 1 {
+			return 0, store.NilAddress, errors.New("malformed typed value block")
+		}
+		return br.GetData()[0], br.GetChildAddress(0), nil
+	default:
+		return 0, store.NilAddress, ErrNotData
+	}
+}
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"encoding/binary"
+
 	"github.com/draganm/chaintrackdb/store"
 	"github.com/pkg/errors"
 )
@@ -15,3 +17,30 @@ func StoreData(st store.ReaderWriter, data []byte, segSize, fanout int) (store.A
 	return w.Finish()
 
 }
+
+// Size returns the number of bytes of data stored at addr.
+func Size(r store.Reader, addr store.Address) (uint64, error) {
+	br, err := r.GetBlock(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	switch br.Type() {
+	case store.TypeDataLeaf:
+		return uint64(len(br.GetData())), nil
+	case store.TypeDataNode:
+		d := br.GetData()
+		if len(d) < 8 {
+			return 0, errors.New("data node is too short")
+		}
+		return binary.BigEndian.Uint64(d), nil
+	case store.TypeTypedValue:
+		_, da, err := ValueType(r, addr)
+		if err != nil {
+			return 0, err
+		}
+		return Size(r, da)
+	default:
+		return 0, ErrNotData
+	}
+}
@@ -1,12 +1,43 @@
 package chaintrackdb
 
 import (
+	"sync"
+	"time"
+
 	"github.com/draganm/chaintrackdb/store"
 	"github.com/pkg/errors"
 )
 
 type DB struct {
-	s *store.Store
+	s         *store.Store
+	commitMu  *sync.Mutex
+	commitLog []commitLogEntry
+
+	// MaxBatchSize is the maximum size of a batch. Default value is
+	// DefaultMaxBatchSize.
+	//
+	// If <=0, disables batching.
+	//
+	// Do not change concurrently with calls to Batch.
+	MaxBatchSize int
+
+	// MaxBatchDelay is the maximum delay before a batch starts.
+	// Default value is DefaultMaxBatchDelay.
+	//
+	// If <=0, effectively disables batching.
+	//
+	// Do not change concurrently with calls to Batch.
+	MaxBatchDelay time.Duration
+
+	batchMu *sync.Mutex
+	batch   *batch
+
+	indexMu         *sync.RWMutex
+	indexes         map[string]*index
+	indexGeneration uint64
+
+	mergeMu        *sync.RWMutex
+	mergeOperators mergeOperators
 }
 
 func Open(path string) (*DB, error) {
@@ -15,9 +46,44 @@ func Open(path string) (*DB, error) {
 		return nil, errors.Wrap(err, "while opening db")
 	}
 
+	return newDB(s), nil
+}
+
+// OpenInMemory opens an empty database kept in memory.
+// Segments are limited to store.DefaultMaxMemoryFileSize bytes, see store.NewMemoryBackend.
+// All data is released when the database is closed.
+func OpenInMemory() (*DB, error) {
+	s, err := store.OpenBackend(store.NewMemoryBackend(store.DefaultMaxMemoryFileSize))
+	if err != nil {
+		return nil, errors.Wrap(err, "while opening db")
+	}
+
+	return newDB(s), nil
+}
+
+// OpenReadOnly opens an existing database without modifying it.
+// Only read transactions can be used, write transactions fail with ErrReadOnly.
+func OpenReadOnly(path string) (*DB, error) {
+	s, err := store.OpenReadOnly(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "while opening db")
+	}
+
+	return newDB(s), nil
+}
+
+func newDB(s *store.Store) *DB {
 	return &DB{
-		s: s,
-	}, nil
+		s:              s,
+		commitMu:       new(sync.Mutex),
+		MaxBatchSize:   DefaultMaxBatchSize,
+		MaxBatchDelay:  DefaultMaxBatchDelay,
+		batchMu:        new(sync.Mutex),
+		indexMu:        new(sync.RWMutex),
+		indexes:        map[string]*index{},
+		mergeMu:        new(sync.RWMutex),
+		mergeOperators: mergeOperators{},
+	}
 }
 
 func (d *DB) Close() error {
@@ -27,3 +93,18 @@ func (d *DB) Close() error {
 func (d *DB) PrintStats() {
 	d.s.PrintStats()
 }
+
+// Stats returns space usage of the last commited state of the database.
+func (d *DB) Stats() (store.Stats, error) {
+	return d.s.Stats()
+}
+
+// Compact rewrites all data of the last commited state into a new segment
+// and removes segments that are not used anymore.
+func (d *DB) Compact() error {
+	_, err := d.s.Compact()
+	if err != nil {
+		return errors.Wrap(err, "while compacting")
+	}
+	return nil
+}
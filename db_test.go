@@ -1,6 +1,7 @@
 package chaintrackdb_test
 
 import (
+	"bytes"
 	"context"
 	"io/ioutil"
 	"os"
@@ -32,6 +33,16 @@ func NewEmptyDB(t *testing.T) (*chaintrackdb.DB, func()) {
 	}
 }
 
+func NewInMemoryDB(t *testing.T) (*chaintrackdb.DB, func()) {
+	db, err := chaintrackdb.OpenInMemory()
+	require.NoError(t, err)
+
+	return db, func() {
+		err = db.Close()
+		require.NoError(t, err)
+	}
+}
+
 func TestOpenAndCloseEmptyDatabase(t *testing.T) {
 	td, cleanup := NewTempDir(t)
 	defer cleanup()
@@ -169,3 +180,82 @@ func TestPuttingDataIntoSecondLevel(t *testing.T) {
 	})
 
 }
+
+func TestStreamingValues(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	value := bytes.Repeat([]byte("0123456789"), 100000)
+
+	t.Run("when I put a value from a reader", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.PutAllFrom("a/b", bytes.NewReader(value))
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should be able to read it with a reader", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				r, err := tx.GetReader("a/b")
+				require.NoError(t, err)
+				d, err := ioutil.ReadAll(r)
+				require.NoError(t, err)
+				require.Equal(t, value, d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then reading a map with a reader should fail with ErrIsMap", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.GetReader("a")
+				return err
+			})
+			require.Equal(t, chaintrackdb.ErrIsMap, err)
+		})
+	})
+}
+
+func TestInMemoryDatabase(t *testing.T) {
+	db, cleanup := NewInMemoryDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I put data in many transactions", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.PutAll("a/b", bytes.Repeat([]byte{byte(i)}, 1000))
+			})
+			require.NoError(t, err)
+		}
+
+		t.Run("then the last value should be stored", func(t *testing.T) {
+			var d []byte
+			err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				var err error
+				d, err = tx.Get("a/b")
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, bytes.Repeat([]byte{99}, 1000), d)
+		})
+
+		t.Run("and when I compact the database", func(t *testing.T) {
+			err := db.Compact()
+			require.NoError(t, err)
+
+			t.Run("then the value should still be stored", func(t *testing.T) {
+				var d []byte
+				err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					var err error
+					d, err = tx.Get("a/b")
+					return err
+				})
+				require.NoError(t, err)
+				require.Equal(t, bytes.Repeat([]byte{99}, 1000), d)
+			})
+		})
+	})
+}
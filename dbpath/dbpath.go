@@ -1,6 +1,7 @@
 package dbpath
 
 import (
+	serrors "errors"
 	"net/url"
 	"strings"
 
@@ -9,6 +10,17 @@ import (
 
 const Separator = "/"
 
+// EmptyPart is the escaped form of an empty path segment.
+const EmptyPart = "%"
+
+// ErrDotSegment is returned when a path contains an unescaped "." or ".." segment.
+var ErrDotSegment = serrors.New("'.' and '..' segments must be escaped")
+
+// ErrEmptySegment is returned by Parse when a path contains an empty segment that is not escaped.
+var ErrEmptySegment = serrors.New("empty segments must be escaped as '%'")
+
+// Split splits the path into unescaped parts.
+// Empty parts are skipped, an empty key can be expressed with EmptyPart.
 func Split(path string) ([]string, error) {
 
 	parts := strings.Split(path, Separator)
@@ -16,13 +28,14 @@ func Split(path string) ([]string, error) {
 	res := []string{}
 
 	for i, p := range parts {
+		if p == "" {
+			continue
+		}
 		up, err := UnescapePart(p)
 		if err != nil {
 			return nil, errors.Wrapf(err, "while unescaping part at position %d: %q", i, p)
 		}
-		if up != "" {
-			res = append(res, up)
-		}
+		res = append(res, up)
 	}
 
 	return res, nil
@@ -36,10 +49,86 @@ func Join(parts ...string) string {
 	return strings.Join(escaped, Separator)
 }
 
+// EscapePart escapes the part so that it can be used as a path segment.
+// Empty parts and dot parts are escaped as well, so every part survives a Split.
 func EscapePart(part string) string {
-	return url.PathEscape(part)
+	switch part {
+	case "":
+		return EmptyPart
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	default:
+		return url.PathEscape(part)
+	}
 }
 
+// UnescapePart unescapes a path segment.
 func UnescapePart(part string) (string, error) {
-	return url.PathUnescape(part)
+	switch part {
+	case EmptyPart:
+		return "", nil
+	case ".", "..":
+		return "", ErrDotSegment
+	default:
+		return url.PathUnescape(part)
+	}
+}
+
+// Path is a path made of binary safe segments.
+type Path [][]byte
+
+// Parse parses a path written by Path.String.
+// Unlike Split, Parse doesn't skip empty segments but returns ErrEmptySegment.
+// An empty string is the root path.
+func Parse(s string) (Path, error) {
+	if s == "" {
+		return Path{}, nil
+	}
+
+	parts := strings.Split(s, Separator)
+	p := make(Path, len(parts))
+
+	for i, part := range parts {
+		if part == "" {
+			return nil, errors.Wrapf(ErrEmptySegment, "at position %d", i)
+		}
+
+		up, err := UnescapePart(part)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while unescaping part at position %d: %q", i, part)
+		}
+		p[i] = []byte(up)
+	}
+
+	return p, nil
+}
+
+// New creates a path from string segments.
+func New(parts ...string) Path {
+	p := make(Path, len(parts))
+	for i, part := range parts {
+		p[i] = []byte(part)
+	}
+	return p
+}
+
+// String returns the escaped form of the path, that can be parsed with Parse or Split.
+func (p Path) String() string {
+	return Join(p.Parts()...)
+}
+
+// Parts returns the segments of the path as strings.
+func (p Path) Parts() []string {
+	parts := make([]string, len(p))
+	for i, s := range p {
+		parts[i] = string(s)
+	}
+	return parts
+}
+
+// Append returns a new path with the segments appended.
+func (p Path) Append(segments ...[]byte) Path {
+	return append(p[:len(p):len(p)], segments...)
 }
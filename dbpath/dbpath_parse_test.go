@@ -0,0 +1,99 @@
+package dbpath_test
+
+import (
+	"testing"
+
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	roundTrips := []struct {
+		title string
+		path  dbpath.Path
+		str   string
+	}{
+		{
+			title: "root",
+			path:  dbpath.Path{},
+			str:   "",
+		},
+		{
+			title: "words",
+			path:  dbpath.New("foo", "bar"),
+			str:   "foo/bar",
+		},
+		{
+			title: "empty segments",
+			path:  dbpath.Path{[]byte{}, []byte("a"), []byte{}},
+			str:   "%/a/%",
+		},
+		{
+			title: "dots",
+			path:  dbpath.New(".", ".."),
+			str:   "%2E/%2E%2E",
+		},
+		{
+			title: "binary",
+			path:  dbpath.Path{[]byte{0, 1, 255, '/', '%'}},
+			str:   "%00%01%FF%2F%25",
+		},
+	}
+
+	for _, tc := range roundTrips {
+		t.Run(tc.title, func(t *testing.T) {
+			require.Equal(t, tc.str, tc.path.String())
+
+			p, err := dbpath.Parse(tc.str)
+			require.NoError(t, err)
+			require.Equal(t, len(tc.path), len(p))
+			for i := range p {
+				require.Equal(t, string(tc.path[i]), string(p[i]))
+			}
+
+			parts, err := dbpath.Split(tc.str)
+			require.NoError(t, err)
+			require.Equal(t, tc.path.Parts(), parts)
+		})
+	}
+
+	errorCases := []struct {
+		title         string
+		str           string
+		expectedError error
+	}{
+		{
+			title:         "unescaped empty segment",
+			str:           "a//b",
+			expectedError: dbpath.ErrEmptySegment,
+		},
+		{
+			title:         "leading separator",
+			str:           "/a",
+			expectedError: dbpath.ErrEmptySegment,
+		},
+		{
+			title:         "dot",
+			str:           "a/.",
+			expectedError: dbpath.ErrDotSegment,
+		},
+		{
+			title:         "dot dot",
+			str:           "../a",
+			expectedError: dbpath.ErrDotSegment,
+		},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.title, func(t *testing.T) {
+			_, err := dbpath.Parse(tc.str)
+			require.Equal(t, tc.expectedError, errors.Cause(err))
+		})
+	}
+
+	t.Run("malformed escape", func(t *testing.T) {
+		_, err := dbpath.Parse("a/%zz")
+		require.EqualError(t, err, "while unescaping part at position 1: \"%zz\": invalid URL escape \"%zz\"")
+	})
+}
@@ -45,6 +45,30 @@ func TestSplit(t *testing.T) {
 			expectedResult: []string{" "},
 			expectedError:  "",
 		},
+		{
+			title:          "escaped empty part",
+			path:           "a/%/b",
+			expectedResult: []string{"a", "", "b"},
+			expectedError:  "",
+		},
+		{
+			title:          "escaped dots",
+			path:           "%2E/%2E%2E",
+			expectedResult: []string{".", ".."},
+			expectedError:  "",
+		},
+		{
+			title:          "dot",
+			path:           "a/./b",
+			expectedResult: nil,
+			expectedError:  "while unescaping part at position 1: \".\": '.' and '..' segments must be escaped",
+		},
+		{
+			title:          "dot dot",
+			path:           "a/..",
+			expectedResult: nil,
+			expectedError:  "while unescaping part at position 1: \"..\": '.' and '..' segments must be escaped",
+		},
 		{
 			title:          "invalid",
 			path:           "%%/",
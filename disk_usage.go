@@ -0,0 +1,75 @@
+package chaintrackdb
+
+import (
+	serrors "errors"
+	"sort"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// Usage is the number of bytes used by a key of a map.
+type Usage struct {
+	// Key is the unescaped key.
+	Key   string
+	Bytes uint64
+}
+
+// DiskUsage returns the number of bytes used by the value or the map at path,
+// including all of the map's contents.
+// Blocks shared with other paths are included.
+func (w *WriteTransaction) DiskUsage(path string) (uint64, error) {
+	addr, err := w.pathElementAddress(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return store.LiveSize(w.swt, addr)
+}
+
+// ErrNegativeN is returned by TopN when n is negative.
+var ErrNegativeN = serrors.New("n must not be negative")
+
+// TopN returns up to n keys of the map at path using the most bytes, biggest first.
+func (w *WriteTransaction) TopN(path string, n int) ([]Usage, error) {
+	if n < 0 {
+		return nil, ErrNegativeN
+	}
+
+	addr, err := w.pathElementAddress(path)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := []Usage{}
+
+	if n == 0 {
+		return usages, nil
+	}
+
+	err = btree.ForEach(w.swt, addr, func(key []byte, value store.Address) error {
+		size, err := store.LiveSize(w.swt, value)
+		if err != nil {
+			return errors.Wrapf(err, "while getting size of %q", key)
+		}
+		usages = append(usages, Usage{Key: string(key), Bytes: size})
+		return nil
+	})
+	if err == btree.ErrNotBTreeNode {
+		return nil, ErrNotMap
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		return usages[i].Bytes > usages[j].Bytes
+	})
+
+	if len(usages) > n {
+		usages = usages[:n]
+	}
+
+	return usages, nil
+}
@@ -0,0 +1,88 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsage(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.PutAll("tenants/small/v", make([]byte, 10))
+		require.NoError(t, err)
+		err = tx.PutAll("tenants/big/v", make([]byte, 10000))
+		require.NoError(t, err)
+		return tx.PutAll("tenants/medium/v", make([]byte, 1000))
+	})
+	require.NoError(t, err)
+
+	err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		t.Run("when I get disk usage of a value", func(t *testing.T) {
+			du, err := tx.DiskUsage("tenants/big/v")
+			require.NoError(t, err)
+			t.Run("then it should include the value's data", func(t *testing.T) {
+				require.True(t, du > 10000)
+			})
+		})
+
+		t.Run("when I get disk usage of a map", func(t *testing.T) {
+			du, err := tx.DiskUsage("tenants")
+			require.NoError(t, err)
+			t.Run("then it should include all the contents", func(t *testing.T) {
+				require.True(t, du > 11010)
+			})
+		})
+
+		t.Run("when I get the top 2 keys of a map", func(t *testing.T) {
+			top, err := tx.TopN("tenants", 2)
+			require.NoError(t, err)
+			t.Run("then I should get the biggest keys first", func(t *testing.T) {
+				require.Len(t, top, 2)
+				require.Equal(t, "big", top[0].Key)
+				require.Equal(t, "medium", top[1].Key)
+
+				du, err := tx.DiskUsage("tenants/big")
+				require.NoError(t, err)
+				require.Equal(t, du, top[0].Bytes)
+			})
+		})
+
+		t.Run("when I get the top 0 keys of a map", func(t *testing.T) {
+			top, err := tx.TopN("tenants", 0)
+			t.Run("then I should get no keys", func(t *testing.T) {
+				require.NoError(t, err)
+				require.Empty(t, top)
+			})
+		})
+
+		t.Run("when I get the top keys with a negative n", func(t *testing.T) {
+			_, err := tx.TopN("tenants", -1)
+			t.Run("then I should get ErrNegativeN", func(t *testing.T) {
+				require.Equal(t, chaintrackdb.ErrNegativeN, err)
+			})
+		})
+
+		t.Run("when I get the top keys of a value", func(t *testing.T) {
+			_, err := tx.TopN("tenants/big/v", 2)
+			t.Run("then I should get ErrNotMap", func(t *testing.T) {
+				require.Equal(t, chaintrackdb.ErrNotMap, err)
+			})
+		})
+
+		t.Run("when I get disk usage of a path that does not exist", func(t *testing.T) {
+			_, err := tx.DiskUsage("tenants/none")
+			t.Run("then I should get ErrNotFound", func(t *testing.T) {
+				require.Equal(t, chaintrackdb.ErrNotFound, err)
+			})
+		})
+		return nil
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,393 @@
+package chaintrackdb
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	serrors "errors"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ExportFormat is the format of the stream written by Export and read by Import.
+type ExportFormat int
+
+const (
+	// ExportJSONLines writes a header line followed by a JSON object for every map and value.
+	// Values are base64 encoded.
+	ExportJSONLines ExportFormat = iota
+	// ExportTar writes a tar archive where maps are directories and values are files.
+	// Types of values are stored in the CHAINTRACKDB.type PAX record.
+	ExportTar
+)
+
+// ErrInvalidExport is returned when importing a stream that was not written by Export.
+var ErrInvalidExport = serrors.New("invalid export")
+
+// importBatchSize is the number of entries imported in one transaction.
+const importBatchSize = 1000
+
+const tarTypeRecord = "CHAINTRACKDB.type"
+
+// exportEntry is a map or a value, the path is relative to the exported prefix.
+type exportEntry struct {
+	path      []string
+	isMap     bool
+	valueType ValueType
+	value     []byte
+}
+
+// Export writes all maps and values under the map at prefix to w.
+// The export is made from a consistent snapshot, merge operands are folded into values.
+func (d *DB) Export(ctx context.Context, prefix string, w io.Writer, format ExportFormat) error {
+	ew, err := newExportWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	tx := d.NewReadTransaction()
+	defer tx.Close()
+
+	addr, err := tx.pathElementAddress(prefix)
+	if err != nil {
+		return err
+	}
+
+	var walk func(parts []string, addr store.Address) error
+	walk = func(parts []string, addr store.Address) error {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		br, err := tx.rtx.GetBlock(addr)
+		if err != nil {
+			return errors.Wrap(err, "while reading block")
+		}
+
+		if br.Type() != store.TypeBTreeNode {
+			vt, v, err := readValue(tx.rtx, tx.mergeOperators, addr)
+			if err != nil {
+				return errors.Wrapf(err, "while reading %q", dbpath.Join(parts...))
+			}
+			return ew.write(exportEntry{path: parts, valueType: vt, value: v})
+		}
+
+		if len(parts) > 0 {
+			err = ew.write(exportEntry{path: parts, isMap: true})
+			if err != nil {
+				return err
+			}
+		}
+
+		return btree.ForEach(tx.rtx, addr, func(key []byte, value store.Address) error {
+			return walk(append(parts[:len(parts):len(parts)], string(key)), value)
+		})
+	}
+
+	br, err := tx.rtx.GetBlock(addr)
+	if err != nil {
+		return errors.Wrap(err, "while reading block")
+	}
+
+	if br.Type() != store.TypeBTreeNode {
+		return ErrNotMap
+	}
+
+	err = walk([]string{}, addr)
+	if err != nil {
+		return err
+	}
+
+	return ew.close()
+}
+
+// Import reads a stream written by Export and stores its maps and values under prefix.
+// Missing maps on the prefix are created, existing values are overwritten.
+// Entries are imported in batches, each one in its own transaction.
+func (d *DB) Import(ctx context.Context, prefix string, r io.Reader, format ExportFormat) error {
+	prefixParts, err := dbpath.Split(prefix)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", prefix)
+	}
+
+	er, err := newExportReader(r, format)
+	if err != nil {
+		return err
+	}
+
+	batch := []exportEntry{}
+
+	flush := func() error {
+		for {
+			err := d.WriteTransaction(ctx, func(tx *WriteTransaction) error {
+				if len(prefixParts) > 0 {
+					err := tx.CreateMapAll(prefix)
+					if err != nil {
+						return err
+					}
+				}
+
+				for _, e := range batch {
+					path := dbpath.Join(append(prefixParts[:len(prefixParts):len(prefixParts)], e.path...)...)
+					if e.isMap {
+						err := tx.CreateMapAll(path)
+						if err != nil {
+							return err
+						}
+						continue
+					}
+
+					err := tx.put(path, e.valueType, e.value, true)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err == ErrConflict {
+				continue
+			}
+			batch = batch[:0]
+			return err
+		}
+	}
+
+	for {
+		e, err := er.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(e.path) == 0 {
+			return errors.Wrap(ErrInvalidExport, "entry without a path")
+		}
+
+		batch = append(batch, e)
+
+		if len(batch) >= importBatchSize {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+type exportWriter interface {
+	write(e exportEntry) error
+	close() error
+}
+
+type exportReader interface {
+	// next returns io.EOF after the last entry.
+	next() (exportEntry, error)
+}
+
+func newExportWriter(w io.Writer, format ExportFormat) (exportWriter, error) {
+	switch format {
+	case ExportJSONLines:
+		enc := json.NewEncoder(w)
+		err := enc.Encode(jsonHeader{Format: jsonExportFormat, Version: 1})
+		if err != nil {
+			return nil, errors.Wrap(err, "while writing header")
+		}
+		return &jsonExportWriter{enc: enc}, nil
+	case ExportTar:
+		return &tarExportWriter{tw: tar.NewWriter(w)}, nil
+	default:
+		return nil, errors.Errorf("unknown export format %d", format)
+	}
+}
+
+func newExportReader(r io.Reader, format ExportFormat) (exportReader, error) {
+	switch format {
+	case ExportJSONLines:
+		dec := json.NewDecoder(r)
+		h := jsonHeader{}
+		err := dec.Decode(&h)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidExport, err.Error())
+		}
+		if h.Format != jsonExportFormat || h.Version != 1 {
+			return nil, errors.Wrapf(ErrInvalidExport, "unsupported format %q version %d", h.Format, h.Version)
+		}
+		return &jsonExportReader{dec: dec}, nil
+	case ExportTar:
+		return &tarExportReader{tr: tar.NewReader(r)}, nil
+	default:
+		return nil, errors.Errorf("unknown export format %d", format)
+	}
+}
+
+func valueTypeFromName(name string) (ValueType, error) {
+	for vt, n := range valueTypeNames {
+		if n == name {
+			return vt, nil
+		}
+	}
+	return ValueTypeBytes, errors.Wrapf(ErrInvalidExport, "unknown value type %q", name)
+}
+
+const jsonExportFormat = "chaintrackdb-export"
+
+type jsonHeader struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+}
+
+type jsonEntry struct {
+	Path  string `json:"path"`
+	Map   bool   `json:"map,omitempty"`
+	Type  string `json:"type,omitempty"`
+	Value []byte `json:"value,omitempty"`
+}
+
+type jsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonExportWriter) write(e exportEntry) error {
+	je := jsonEntry{Path: dbpath.Join(e.path...), Map: e.isMap}
+	if !e.isMap {
+		je.Type = e.valueType.String()
+		je.Value = e.value
+	}
+
+	err := j.enc.Encode(je)
+	if err != nil {
+		return errors.Wrap(err, "while writing entry")
+	}
+	return nil
+}
+
+func (j *jsonExportWriter) close() error {
+	return nil
+}
+
+type jsonExportReader struct {
+	dec *json.Decoder
+}
+
+func (j *jsonExportReader) next() (exportEntry, error) {
+	je := jsonEntry{}
+	err := j.dec.Decode(&je)
+	if err == io.EOF {
+		return exportEntry{}, io.EOF
+	}
+	if err != nil {
+		return exportEntry{}, errors.Wrap(ErrInvalidExport, err.Error())
+	}
+
+	path, err := dbpath.Split(je.Path)
+	if err != nil {
+		return exportEntry{}, errors.Wrapf(ErrInvalidExport, "path %q: %s", je.Path, err)
+	}
+
+	if je.Map {
+		return exportEntry{path: path, isMap: true}, nil
+	}
+
+	vt, err := valueTypeFromName(je.Type)
+	if err != nil {
+		return exportEntry{}, err
+	}
+
+	return exportEntry{path: path, valueType: vt, value: je.Value}, nil
+}
+
+type tarExportWriter struct {
+	tw *tar.Writer
+}
+
+func (t *tarExportWriter) write(e exportEntry) error {
+	name := dbpath.Join(e.path...)
+
+	if e.isMap {
+		err := t.tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     name + "/",
+			Mode:     0755,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "while writing directory %q", name)
+		}
+		return nil
+	}
+
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       name,
+		Mode:       0644,
+		Size:       int64(len(e.value)),
+		Format:     tar.FormatPAX,
+		PAXRecords: map[string]string{tarTypeRecord: e.valueType.String()},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "while writing header of %q", name)
+	}
+
+	_, err = t.tw.Write(e.value)
+	if err != nil {
+		return errors.Wrapf(err, "while writing %q", name)
+	}
+
+	return nil
+}
+
+func (t *tarExportWriter) close() error {
+	return t.tw.Close()
+}
+
+type tarExportReader struct {
+	tr *tar.Reader
+}
+
+func (t *tarExportReader) next() (exportEntry, error) {
+	h, err := t.tr.Next()
+	if err == io.EOF {
+		return exportEntry{}, io.EOF
+	}
+	if err != nil {
+		return exportEntry{}, errors.Wrap(ErrInvalidExport, err.Error())
+	}
+
+	path, err := dbpath.Split(h.Name)
+	if err != nil {
+		return exportEntry{}, errors.Wrapf(ErrInvalidExport, "path %q: %s", h.Name, err)
+	}
+
+	switch h.Typeflag {
+	case tar.TypeDir:
+		return exportEntry{path: path, isMap: true}, nil
+	case tar.TypeReg:
+		vt := ValueTypeBytes
+		name, found := h.PAXRecords[tarTypeRecord]
+		if found {
+			vt, err = valueTypeFromName(name)
+			if err != nil {
+				return exportEntry{}, err
+			}
+		}
+
+		v, err := ioutil.ReadAll(t.tr)
+		if err != nil {
+			return exportEntry{}, errors.Wrapf(err, "while reading %q", h.Name)
+		}
+
+		return exportEntry{path: path, valueType: vt, value: v}, nil
+	default:
+		return exportEntry{}, errors.Wrapf(ErrInvalidExport, "%q has unsupported type flag %q", h.Name, h.Typeflag)
+	}
+}
@@ -0,0 +1,100 @@
+package chaintrackdb_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		require.NoError(t, tx.PutAll("src/a/b", []byte("bytes")))
+		require.NoError(t, tx.PutAll("src/a/c%20d", nil))
+		require.NoError(t, tx.CreateMapAll("src/empty"))
+		require.NoError(t, tx.PutUint64("src/n", 42))
+		require.NoError(t, tx.PutString("src/s", "string"))
+		require.NoError(t, tx.PutJSON("src/j", []string{"x"}))
+		return tx.PutAll("src/big", bytes.Repeat([]byte{7}, 200000))
+	})
+	require.NoError(t, err)
+
+	formats := map[string]chaintrackdb.ExportFormat{
+		"json": chaintrackdb.ExportJSONLines,
+		"tar":  chaintrackdb.ExportTar,
+	}
+
+	for name, format := range formats {
+		t.Run("when I export and import "+name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			err := db.Export(ctx, "src", buf, format)
+			require.NoError(t, err)
+
+			err = db.Import(ctx, "dst/"+name, bytes.NewReader(buf.Bytes()), format)
+			require.NoError(t, err)
+
+			t.Run("then the imported maps and values should be the same", func(t *testing.T) {
+				err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+					dst := "dst/" + name + "/"
+
+					d, err := tx.Get(dst + "a/b")
+					require.NoError(t, err)
+					require.Equal(t, []byte("bytes"), d)
+
+					d, err = tx.Get(dst + "a/c%20d")
+					require.NoError(t, err)
+					require.Empty(t, d)
+
+					cnt, err := tx.Count(dst + "empty")
+					require.NoError(t, err)
+					require.Equal(t, uint64(0), cnt)
+
+					n, err := tx.GetUint64(dst + "n")
+					require.NoError(t, err)
+					require.Equal(t, uint64(42), n)
+
+					s, err := tx.GetString(dst + "s")
+					require.NoError(t, err)
+					require.Equal(t, "string", s)
+
+					j := []string{}
+					require.NoError(t, tx.GetJSON(dst+"j", &j))
+					require.Equal(t, []string{"x"}, j)
+
+					d, err = tx.Get(dst + "big")
+					require.NoError(t, err)
+					require.Equal(t, bytes.Repeat([]byte{7}, 200000), d)
+
+					cnt, err = tx.Count("dst/" + name)
+					require.NoError(t, err)
+					require.Equal(t, uint64(6), cnt)
+					return nil
+				})
+				require.NoError(t, err)
+			})
+		})
+	}
+
+	t.Run("when I export a value", func(t *testing.T) {
+		err := db.Export(ctx, "src/n", new(bytes.Buffer), chaintrackdb.ExportJSONLines)
+		t.Run("then I should get ErrNotMap", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrNotMap, err)
+		})
+	})
+
+	t.Run("when I import a stream that is not an export", func(t *testing.T) {
+		err := db.Import(ctx, "x", strings.NewReader(`{"foo":"bar"}`), chaintrackdb.ExportJSONLines)
+		t.Run("then I should get ErrInvalidExport", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrInvalidExport, errors.Cause(err))
+		})
+	})
+}
@@ -0,0 +1,215 @@
+package chaintrackdb
+
+import (
+	serrors "errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrBadPattern is returned by Glob when the pattern is malformed.
+var ErrBadPattern = serrors.New("syntax error in pattern")
+
+// errStopIteration stops iterating over a map.
+var errStopIteration = serrors.New("stop iteration")
+
+const globStar = "**"
+
+// metaChars are the characters that have a special meaning in path.Match patterns.
+const metaChars = `*?[\`
+
+// Glob calls f with the path of every value and map matching the pattern.
+// Pattern segments are matched against escaped path segments using path.Match syntax,
+// a "**" segment matches zero or more segments.
+// Paths are visited in the key order, a map before its contents.
+// Literal prefixes of pattern segments are used to seek in the maps.
+// Iteration stops at the first error returned by f, the error is returned by Glob.
+func (w *WriteTransaction) Glob(pattern string, f func(path string) error) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	segments := []string{}
+	for _, s := range strings.Split(pattern, dbpath.Separator) {
+		// empty segments are ignored, same as in dbpath.Split
+		if s == "" {
+			continue
+		}
+		_, err = path.Match(s, "")
+		if err != nil {
+			return ErrBadPattern
+		}
+		segments = append(segments, s)
+	}
+
+	// everything below the literal part of the pattern is read
+	literal := []string{}
+	for _, s := range segments {
+		if hasMeta(s) {
+			break
+		}
+		p, err := dbpath.UnescapePart(s)
+		if err != nil {
+			return ErrBadPattern
+		}
+		literal = append(literal, p)
+	}
+	w.reads = append(w.reads, literal)
+
+	g := &globber{
+		r:        w.swt,
+		segments: segments,
+		f:        f,
+	}
+
+	return g.walk(w.root, nil, g.closure([]int{0}))
+}
+
+type globber struct {
+	r        store.Reader
+	segments []string
+	f        func(path string) error
+}
+
+// closure adds states reachable by matching "**" to zero segments.
+func (g *globber) closure(states []int) []int {
+	res := []int{}
+	seen := map[int]bool{}
+	for _, s := range states {
+		for !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+			if s == len(g.segments) || g.segments[s] != globStar {
+				break
+			}
+			s++
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+// next returns the states after matching the key in the given states.
+func (g *globber) next(states []int, key string) []int {
+	escaped := dbpath.EscapePart(key)
+	nextStates := []int{}
+	for _, s := range states {
+		if s == len(g.segments) {
+			continue
+		}
+		seg := g.segments[s]
+		if seg == globStar {
+			nextStates = append(nextStates, s)
+			continue
+		}
+		matched, _ := path.Match(seg, escaped)
+		if matched {
+			nextStates = append(nextStates, s+1)
+		}
+	}
+	return g.closure(nextStates)
+}
+
+func (g *globber) walk(ad store.Address, parts []string, states []int) error {
+	visit := func(key []byte, value store.Address) error {
+		ns := g.next(states, string(key))
+		if len(ns) == 0 {
+			return nil
+		}
+
+		childParts := append(parts[:len(parts):len(parts)], string(key))
+
+		if ns[len(ns)-1] == len(g.segments) {
+			err := g.f(dbpath.Join(childParts...))
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(ns) == 1 && ns[0] == len(g.segments) {
+			return nil
+		}
+
+		br, err := g.r.GetBlock(value)
+		if err != nil {
+			return errors.Wrap(err, "while reading block")
+		}
+
+		if br.Type() != store.TypeBTreeNode {
+			return nil
+		}
+
+		return g.walk(value, childParts, ns)
+	}
+
+	prefix, exact, ok := g.literalPrefix(states)
+	if !ok {
+		return btree.ForEach(g.r, ad, visit)
+	}
+
+	if exact {
+		va, err := btree.Get(g.r, ad, []byte(prefix))
+		if err == btree.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return visit([]byte(prefix), va)
+	}
+
+	err := btree.ForEachFrom(g.r, ad, []byte(prefix), func(key []byte, value store.Address) error {
+		if !strings.HasPrefix(string(key), prefix) {
+			return errStopIteration
+		}
+		return visit(key, value)
+	})
+	if err == errStopIteration {
+		return nil
+	}
+	return err
+}
+
+// literalPrefix returns the unescaped prefix all keys matching the states must start with.
+// exact is true if only the key equal to the prefix can match.
+func (g *globber) literalPrefix(states []int) (prefix string, exact bool, ok bool) {
+	if len(states) != 1 || states[0] == len(g.segments) {
+		return "", false, false
+	}
+
+	seg := g.segments[states[0]]
+	if seg == globStar {
+		return "", false, false
+	}
+
+	literal := seg
+	exact = true
+	idx := strings.IndexAny(seg, metaChars)
+	if idx >= 0 {
+		literal = seg[:idx]
+		exact = false
+	}
+
+	// don't cut an escape sequence in half
+	pct := strings.LastIndex(literal, "%")
+	if !exact && pct >= 0 && pct > len(literal)-3 {
+		literal = literal[:pct]
+	}
+
+	prefix, err := dbpath.UnescapePart(literal)
+	if err != nil || dbpath.EscapePart(prefix) != literal {
+		return "", false, false
+	}
+
+	return prefix, exact, true
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, metaChars)
+}
@@ -0,0 +1,153 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		for _, p := range []string{
+			"users/alice/sessions/s1",
+			"users/alice/sessions/s2",
+			"users/bob/sessions/s3",
+			"users/bob/profile",
+			"users/carol%20c/sessions/s4",
+			"groups/admins/alice",
+		} {
+			err := tx.PutAll(p, []byte(p))
+			require.NoError(t, err)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	glob := func(t *testing.T, pattern string) []string {
+		res := []string{}
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Glob(pattern, func(path string) error {
+				res = append(res, path)
+				return nil
+			})
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	cases := []struct {
+		title    string
+		pattern  string
+		expected []string
+	}{
+		{
+			title:   "star segments",
+			pattern: "users/*/sessions/*",
+			expected: []string{
+				"users/alice/sessions/s1",
+				"users/alice/sessions/s2",
+				"users/bob/sessions/s3",
+				"users/carol%20c/sessions/s4",
+			},
+		},
+		{
+			title:   "literal prefix",
+			pattern: "users/b*/*",
+			expected: []string{
+				"users/bob/profile",
+				"users/bob/sessions",
+			},
+		},
+		{
+			title:   "escaped segment",
+			pattern: "users/carol%20*",
+			expected: []string{
+				"users/carol%20c",
+			},
+		},
+		{
+			title:   "character class",
+			pattern: "users/*/sessions/s[13]",
+			expected: []string{
+				"users/alice/sessions/s1",
+				"users/bob/sessions/s3",
+			},
+		},
+		{
+			title:   "double star",
+			pattern: "**/alice",
+			expected: []string{
+				"groups/admins/alice",
+				"users/alice",
+			},
+		},
+		{
+			title:   "double star matching zero segments",
+			pattern: "users/bob/**",
+			expected: []string{
+				"users/bob",
+				"users/bob/profile",
+				"users/bob/sessions",
+				"users/bob/sessions/s3",
+			},
+		},
+		{
+			title:   "double stars matching the same path only once",
+			pattern: "**/sessions/**",
+			expected: []string{
+				"users/alice/sessions",
+				"users/alice/sessions/s1",
+				"users/alice/sessions/s2",
+				"users/bob/sessions",
+				"users/bob/sessions/s3",
+				"users/carol%20c/sessions",
+				"users/carol%20c/sessions/s4",
+			},
+		},
+		{
+			title:    "no match",
+			pattern:  "users/dave/*",
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			require.Equal(t, tc.expected, glob(t, tc.pattern))
+		})
+	}
+
+	t.Run("when the callback returns an error", func(t *testing.T) {
+		stop := errors.New("stop")
+		cnt := 0
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Glob("users/*", func(path string) error {
+				cnt++
+				return stop
+			})
+		})
+		t.Run("then Glob should stop and return the error", func(t *testing.T) {
+			require.Equal(t, stop, err)
+			require.Equal(t, 1, cnt)
+		})
+	})
+
+	t.Run("when the pattern is malformed", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Glob("users/[", func(path string) error {
+				return nil
+			})
+		})
+		t.Run("then I should get ErrBadPattern", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrBadPattern, err)
+		})
+	})
+}
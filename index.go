@@ -0,0 +1,369 @@
+package chaintrackdb
+
+import (
+	"context"
+	serrors "errors"
+	"sort"
+	"time"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// IndexFunc returns the index keys for the value stored at path.
+// The value is indexed under every returned key.
+type IndexFunc func(path string, value []byte) ([]string, error)
+
+// IndexesMap is the map containing all indexes.
+// An index entry for a value at path is stored under IndexesMap/<name>/<index key>/<escaped path>.
+const IndexesMap = "__idx"
+
+// ErrIndexNotFound is returned when using an index that has not been created.
+var ErrIndexNotFound = serrors.New("index not found")
+
+// ErrIndexExists is returned when creating an index with a name that is already used.
+var ErrIndexExists = serrors.New("index already exists")
+
+// maxIndexBuildAttempts is the number of times building an index is attempted
+// before CreateIndex gives up with ErrConflict.
+const maxIndexBuildAttempts = 10
+
+type index struct {
+	name   string
+	prefix []string
+	fn     IndexFunc
+}
+
+// CreateIndex registers an index of all values stored under sourcePrefix and builds it.
+// Once registered, every change under the prefix updates the index in the same transaction.
+// Indexes are not persisted, they have to be registered every time the database is opened.
+// The index is rebuilt from scratch every time it is created, so changes made while
+// it was not registered are indexed as well.
+// Building the index is retried with a backoff when it conflicts with concurrent transactions.
+// If building the index fails, the index is not registered.
+func (d *DB) CreateIndex(ctx context.Context, name, sourcePrefix string, fn IndexFunc) error {
+	prefix, err := dbpath.Split(sourcePrefix)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", sourcePrefix)
+	}
+
+	idx := &index{name: name, prefix: prefix, fn: fn}
+
+	// registering the index first makes transactions started before the build conflict,
+	// so no change under the prefix can be commited without maintaining the index
+	d.indexMu.Lock()
+	_, found := d.indexes[name]
+	if !found {
+		d.indexes[name] = idx
+		d.indexGeneration++
+	}
+	d.indexMu.Unlock()
+
+	if found {
+		return ErrIndexExists
+	}
+
+	err = d.buildIndex(ctx, name)
+
+	if err != nil {
+		d.indexMu.Lock()
+		if d.indexes[name] == idx {
+			delete(d.indexes, name)
+			d.indexGeneration++
+		}
+		d.indexMu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// buildIndex rebuilds the index, retrying with an exponential backoff on conflicts.
+func (d *DB) buildIndex(ctx context.Context, name string) error {
+	backoff := time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		err := d.WriteTransaction(ctx, func(tx *WriteTransaction) error {
+			return tx.rebuildIndex(name)
+		})
+		if err != ErrConflict || attempt == maxIndexBuildAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// RebuildIndex builds the index from scratch.
+func (d *DB) RebuildIndex(ctx context.Context, name string) error {
+	return d.WriteTransaction(ctx, func(tx *WriteTransaction) error {
+		return tx.rebuildIndex(name)
+	})
+}
+
+// LookupIndex returns paths of all values indexed under the key, in the key order.
+func (w *WriteTransaction) LookupIndex(name, key string) ([]string, error) {
+	if w.indexes[name] == nil {
+		return nil, ErrIndexNotFound
+	}
+
+	addr, err := w.pathElementAddress(dbpath.Join(IndexesMap, name, key))
+	if err == ErrNotFound {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{}
+	err = btree.ForEach(w.swt, addr, func(key []byte, value store.Address) error {
+		paths = append(paths, string(key))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading index entries")
+	}
+
+	return paths, nil
+}
+
+func (w *WriteTransaction) rebuildIndex(name string) error {
+	idx := w.indexes[name]
+	if idx == nil {
+		return ErrIndexNotFound
+	}
+
+	err := w.CreateMapAll(IndexesMap)
+	if err != nil {
+		return err
+	}
+
+	err = w.Delete(dbpath.Join(IndexesMap, name))
+	if err != nil && errors.Cause(err) != ErrNotFound {
+		return err
+	}
+
+	err = w.CreateMap(dbpath.Join(IndexesMap, name))
+	if err != nil {
+		return err
+	}
+
+	entries, err := w.indexEntries(idx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range sortedEntries(entries) {
+		err = w.putIndexEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// withIndexes calls f that changes the subtree at path and updates all indexes
+// of values under the path.
+func (w *WriteTransaction) withIndexes(path []string, f func() error) error {
+	// indexes are never indexed
+	if len(path) > 0 && path[0] == IndexesMap {
+		return f()
+	}
+
+	affected := []*index{}
+	for _, idx := range w.indexes {
+		if pathsOverlap(idx.prefix, path) {
+			affected = append(affected, idx)
+		}
+	}
+
+	if len(affected) == 0 {
+		return f()
+	}
+
+	before := map[string][]string{}
+	for _, idx := range affected {
+		err := w.collectIndexEntries(before, idx, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := f()
+	if err != nil {
+		return err
+	}
+
+	after := map[string][]string{}
+	for _, idx := range affected {
+		err = w.collectIndexEntries(after, idx, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range sortedEntries(before) {
+		if after[dbpath.Join(e...)] != nil {
+			continue
+		}
+		err = w.removeIndexEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range sortedEntries(after) {
+		if before[dbpath.Join(e...)] != nil {
+			continue
+		}
+		err = w.putIndexEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *WriteTransaction) indexEntries(idx *index, path []string) (map[string][]string, error) {
+	entries := map[string][]string{}
+	err := w.collectIndexEntries(entries, idx, path)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// collectIndexEntries adds the paths of the index entries for all values under
+// both the index prefix and path to entries.
+func (w *WriteTransaction) collectIndexEntries(entries map[string][]string, idx *index, path []string) error {
+	walkRoot := idx.prefix
+	if len(path) > len(walkRoot) {
+		walkRoot = path
+	}
+
+	w.reads = append(w.reads, walkRoot)
+
+	addr, err := pathElementAddress(w.swt, w.root, walkRoot)
+	if err == ErrNotFound || err == ErrNotMap {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var walk func(parts []string, addr store.Address) error
+	walk = func(parts []string, addr store.Address) error {
+		br, err := w.swt.GetBlock(addr)
+		if err != nil {
+			return errors.Wrap(err, "while reading block")
+		}
+
+		if br.Type() == store.TypeBTreeNode {
+			return btree.ForEach(w.swt, addr, func(key []byte, value store.Address) error {
+				// indexes are never indexed
+				if len(parts) == 0 && string(key) == IndexesMap {
+					return nil
+				}
+				return walk(append(parts[:len(parts):len(parts)], string(key)), value)
+			})
+		}
+
+		_, v, err := readValue(w.swt, w.mergeOperators, addr)
+		if err != nil {
+			return err
+		}
+
+		primary := dbpath.Join(parts...)
+		keys, err := idx.fn(primary, v)
+		if err != nil {
+			return errors.Wrapf(err, "while indexing %q in index %q", primary, idx.name)
+		}
+
+		for _, k := range keys {
+			e := []string{IndexesMap, idx.name, k, primary}
+			entries[dbpath.Join(e...)] = e
+		}
+		return nil
+	}
+
+	return walk(walkRoot, addr)
+}
+
+func sortedEntries(entries map[string][]string) [][]string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make([][]string, len(keys))
+	for i, k := range keys {
+		res[i] = entries[k]
+	}
+	return res
+}
+
+func (w *WriteTransaction) putIndexEntry(entry []string) error {
+	empty, err := data.StoreData(w.swt, nil, dataSegSize, dataFanout)
+	if err != nil {
+		return errors.Wrap(err, "while storing index entry")
+	}
+
+	return w.applyWrite(write{
+		path: entry,
+		apply: func(root store.Address) (store.Address, error) {
+			return modifyPath(w.swt, root, entry, true, func(ad store.Address, key string) (store.Address, error) {
+				return btree.Put(w.swt, ad, []byte(key), empty)
+			})
+		},
+	})
+}
+
+// removeIndexEntry removes the entry and the map of the index key if it becomes empty.
+func (w *WriteTransaction) removeIndexEntry(entry []string) error {
+	keyPath := entry[:len(entry)-1]
+	return w.applyWrite(write{
+		path: keyPath,
+		apply: func(root store.Address) (store.Address, error) {
+			return modifyPath(w.swt, root, keyPath, false, func(ad store.Address, key string) (store.Address, error) {
+				ka, err := btree.Get(w.swt, ad, []byte(key))
+				if err == btree.ErrNotFound {
+					return store.NilAddress, ErrNotFound
+				}
+				if err != nil {
+					return store.NilAddress, err
+				}
+
+				ka, err = btree.Delete(w.swt, ka, []byte(entry[len(entry)-1]))
+				if err == btree.ErrNotFound {
+					return store.NilAddress, ErrNotFound
+				}
+				if err != nil {
+					return store.NilAddress, err
+				}
+
+				cnt, err := btree.Count(w.swt, ka)
+				if err != nil {
+					return store.NilAddress, err
+				}
+
+				if cnt == 0 {
+					return btree.Delete(w.swt, ad, []byte(key))
+				}
+
+				return btree.Put(w.swt, ad, []byte(key), ka)
+			})
+		},
+	})
+}
@@ -0,0 +1,263 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexes(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	byEmail := func(path string, value []byte) ([]string, error) {
+		if !strings.HasSuffix(path, "/email") {
+			return nil, nil
+		}
+		return []string{string(value)}, nil
+	}
+
+	lookup := func(t *testing.T, key string) []string {
+		var paths []string
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			var err error
+			paths, err = tx.LookupIndex("email", key)
+			return err
+		})
+		require.NoError(t, err)
+		return paths
+	}
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.PutAll("users/alice/email", []byte("alice@example.com"))
+		require.NoError(t, err)
+		return tx.PutAll("users/alice/name", []byte("Alice"))
+	})
+	require.NoError(t, err)
+
+	tx, err := db.NewWriteTransaction(ctx)
+	require.NoError(t, err)
+
+	t.Run("when I create an index", func(t *testing.T) {
+		err = db.CreateIndex(ctx, "email", "users", byEmail)
+		require.NoError(t, err)
+
+		t.Run("then existing values should be indexed", func(t *testing.T) {
+			require.Equal(t, []string{"users/alice/email"}, lookup(t, "alice@example.com"))
+		})
+
+		t.Run("then a transaction started before should not be commited", func(t *testing.T) {
+			err = tx.PutAll("users/eve/email", []byte("eve@example.com"))
+			require.NoError(t, err)
+			err = tx.Commit()
+			require.Equal(t, chaintrackdb.ErrConflict, err)
+		})
+
+		t.Run("then creating it again should fail", func(t *testing.T) {
+			err = db.CreateIndex(ctx, "email", "users", byEmail)
+			require.Equal(t, chaintrackdb.ErrIndexExists, err)
+		})
+	})
+
+	t.Run("when I put a value under the prefix", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.PutAll("users/bob/email", []byte("bob@example.com"))
+		})
+		require.NoError(t, err)
+
+		t.Run("then it should be indexed", func(t *testing.T) {
+			require.Equal(t, []string{"users/bob/email"}, lookup(t, "bob@example.com"))
+		})
+	})
+
+	t.Run("when I overwrite an indexed value", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Put("users/bob/email", []byte("robert@example.com"))
+		})
+		require.NoError(t, err)
+
+		t.Run("then the old key should not contain it", func(t *testing.T) {
+			require.Equal(t, []string{}, lookup(t, "bob@example.com"))
+			require.Equal(t, []string{"users/bob/email"}, lookup(t, "robert@example.com"))
+		})
+	})
+
+	t.Run("when I copy a map with indexed values", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Copy("users/alice", "users/alice2")
+		})
+		require.NoError(t, err)
+
+		t.Run("then the copied values should be indexed", func(t *testing.T) {
+			require.Equal(t, []string{"users/alice/email", "users/alice2/email"}, lookup(t, "alice@example.com"))
+		})
+	})
+
+	t.Run("when I delete a map with indexed values", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Delete("users/alice")
+		})
+		require.NoError(t, err)
+
+		t.Run("then the values should be removed from the index", func(t *testing.T) {
+			require.Equal(t, []string{"users/alice2/email"}, lookup(t, "alice@example.com"))
+		})
+	})
+
+	t.Run("when I rebuild a damaged index", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Delete(chaintrackdb.IndexesMap + "/email")
+		})
+		require.NoError(t, err)
+
+		err = db.RebuildIndex(ctx, "email")
+		require.NoError(t, err)
+
+		t.Run("then all values should be indexed", func(t *testing.T) {
+			require.Equal(t, []string{"users/alice2/email"}, lookup(t, "alice@example.com"))
+			require.Equal(t, []string{"users/bob/email"}, lookup(t, "robert@example.com"))
+		})
+	})
+
+	t.Run("when I look up an index that does not exist", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			_, err := tx.LookupIndex("nope", "x")
+			return err
+		})
+		t.Run("then I should get ErrIndexNotFound", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrIndexNotFound, err)
+		})
+	})
+}
+
+func TestCreateIndexFailure(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		return tx.PutAll("users/a", []byte("k"))
+	})
+	require.NoError(t, err)
+
+	failure := errors.New("failure")
+	fail := true
+	fn := func(path string, value []byte) ([]string, error) {
+		if fail {
+			return nil, failure
+		}
+		return []string{string(value)}, nil
+	}
+
+	t.Run("when building the index fails", func(t *testing.T) {
+		err = db.CreateIndex(ctx, "i", "users", fn)
+
+		t.Run("then I should get the error", func(t *testing.T) {
+			require.Equal(t, failure, errors.Cause(err))
+		})
+
+		t.Run("then the index should not be registered", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.LookupIndex("i", "k")
+				return err
+			})
+			require.Equal(t, chaintrackdb.ErrIndexNotFound, err)
+		})
+
+		t.Run("then writes under the prefix should not use the index", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("users/b", []byte("k"))
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I create the index again", func(t *testing.T) {
+		fail = false
+		err = db.CreateIndex(ctx, "i", "users", fn)
+		require.NoError(t, err)
+
+		t.Run("then all values should be indexed", func(t *testing.T) {
+			var paths []string
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				paths, err = tx.LookupIndex("i", "k")
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"users/a", "users/b"}, paths)
+		})
+	})
+
+	t.Run("when the context is cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		err = db.CreateIndex(cctx, "j", "users", fn)
+
+		t.Run("then I should get the error", func(t *testing.T) {
+			require.Equal(t, context.Canceled, errors.Cause(err))
+		})
+
+		t.Run("then the index should not be registered", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.LookupIndex("j", "k")
+				return err
+			})
+			require.Equal(t, chaintrackdb.ErrIndexNotFound, err)
+		})
+	})
+}
+
+func TestCreateIndexAfterReopen(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	fn := func(path string, value []byte) ([]string, error) {
+		return []string{string(value)}, nil
+	}
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+
+	err = db.CreateIndex(ctx, "i", "users", fn)
+	require.NoError(t, err)
+
+	err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		return tx.PutAll("users/a", []byte("k"))
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+
+	t.Run("when I write before creating the index after reopening", func(t *testing.T) {
+		db, err = chaintrackdb.Open(td)
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Put("users/b", []byte("k"))
+		})
+		require.NoError(t, err)
+
+		err = db.CreateIndex(ctx, "i", "users", fn)
+		require.NoError(t, err)
+
+		t.Run("then the write should be indexed", func(t *testing.T) {
+			var paths []string
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				paths, err = tx.LookupIndex("i", "k")
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"users/a", "users/b"}, paths)
+		})
+	})
+}
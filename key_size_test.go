@@ -0,0 +1,53 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeySize(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	longKey := strings.Repeat("natural-key-", 1000)
+
+	t.Run("when I put a value and a map with long keys", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			err := tx.CreateMapPath(dbpath.New(longKey))
+			require.NoError(t, err)
+			return tx.PutPath(dbpath.New(longKey, longKey), []byte{1})
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should be able to read the value after commit", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.GetPath(dbpath.New(longKey, longKey))
+				require.NoError(t, err)
+				require.Equal(t, []byte{1}, d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I put a value with a key larger than the max key size", func(t *testing.T) {
+		tooLong := strings.Repeat("x", btree.MaxKeySize+1)
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.PutPath(dbpath.New("a", tooLong), []byte{1})
+		})
+
+		t.Run("then I should get ErrKeyTooLarge naming the segment", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrKeyTooLarge, errors.Cause(err))
+			require.Contains(t, err.Error(), "segment 1")
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionLifecycle(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I roll back a transaction", func(t *testing.T) {
+		tx, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx.Put("abc", []byte{1})
+		require.NoError(t, err)
+
+		err = tx.Rollback()
+		require.NoError(t, err)
+
+		t.Run("then rolling back again should be a no-op", func(t *testing.T) {
+			err = tx.Rollback()
+			require.NoError(t, err)
+		})
+
+		t.Run("then using the transaction should return ErrTxClosed", func(t *testing.T) {
+			err = tx.Put("def", []byte{1})
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+
+			_, err = tx.Get("abc")
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+
+			err = tx.Commit()
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+
+		t.Run("then the changes should be discarded", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				exists, err := tx.Exists("abc")
+				require.NoError(t, err)
+				require.False(t, exists)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I commit a transaction twice", func(t *testing.T) {
+		tx, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx.Put("abc", []byte{1})
+		require.NoError(t, err)
+
+		err = tx.Commit()
+		require.NoError(t, err)
+
+		t.Run("then the second commit should return ErrTxClosed", func(t *testing.T) {
+			err = tx.Commit()
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+
+		t.Run("then rolling back should be a no-op", func(t *testing.T) {
+			err = tx.Rollback()
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when the context of a transaction is cancelled", func(t *testing.T) {
+		cctx, cancel := context.WithCancel(ctx)
+
+		tx, err := db.NewWriteTransaction(cctx)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.Put("def", []byte{1})
+		require.NoError(t, err)
+
+		cancel()
+
+		t.Run("then the transaction should be closed", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				_, err := tx.Exists("def")
+				return err == chaintrackdb.ErrTxClosed
+			}, time.Second, time.Millisecond)
+
+			err = tx.Commit()
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+
+		t.Run("then other transactions should commit", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("def", []byte{2})
+			})
+			require.NoError(t, err)
+		})
+	})
+
+}
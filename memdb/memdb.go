@@ -0,0 +1,275 @@
+// Package memdb is an in-memory implementation of the chaintrackdb transactions,
+// meant for unit tests of code using chaintrackdb.Reader and chaintrackdb.ReadWriter.
+// Transactions behave like the chaintrackdb ones: they are optimistic,
+// return the same errors and conflict on the same paths.
+package memdb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/pkg/errors"
+)
+
+// DB is an in-memory database.
+type DB struct {
+	mu        *sync.Mutex
+	root      *node
+	version   uint64
+	commitLog []commitLogEntry
+	active    map[*WriteTransaction]bool
+}
+
+// commitLogEntry records the paths written by a commited transaction.
+type commitLogEntry struct {
+	version uint64
+	paths   [][]string
+}
+
+// New creates an empty database.
+func New() *DB {
+	return &DB{
+		mu:     new(sync.Mutex),
+		root:   emptyMap(),
+		active: map[*WriteTransaction]bool{},
+	}
+}
+
+// NewReadTransaction starts a read transaction of the last commited state.
+func (d *DB) NewReadTransaction() *ReadTransaction {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &ReadTransaction{root: d.root}
+}
+
+// ReadTransaction runs f in a new read transaction.
+func (d *DB) ReadTransaction(ctx context.Context, f func(tx *ReadTransaction) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	tx := d.NewReadTransaction()
+	defer tx.Close()
+
+	return f(tx)
+}
+
+// NewWriteTransaction starts a write transaction from the last commited state.
+// The transaction is closed when ctx is done.
+func (d *DB) NewWriteTransaction(ctx context.Context) (*WriteTransaction, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w := &WriteTransaction{
+		db:   d,
+		ctx:  ctx,
+		base: d.version,
+		root: d.root,
+	}
+	d.active[w] = true
+
+	return w, nil
+}
+
+// WriteTransaction runs f in a new write transaction and commits it if f doesn't return an error.
+// chaintrackdb.ErrConflict is returned if the transaction could not be commited
+// because of a concurrent transaction, in which case f can be retried.
+func (d *DB) WriteTransaction(ctx context.Context, f func(tx *WriteTransaction) error) error {
+	tx, err := d.NewWriteTransaction(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = f(tx)
+	if err != nil {
+		rbe := tx.Rollback()
+		if rbe != nil {
+			return errors.Wrap(err, "while rolling back transaction")
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (d *DB) commit(w *WriteTransaction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	defer d.pruneCommitLog()
+
+	delete(d.active, w)
+
+	root := w.root
+	if d.version != w.base {
+		if d.hasConflict(w) {
+			return chaintrackdb.ErrConflict
+		}
+
+		var err error
+		root, err = w.rebase(d.root)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(w.writes) == 0 {
+		return nil
+	}
+
+	d.root = root
+	d.version++
+
+	paths := make([][]string, len(w.writes))
+	for i, wr := range w.writes {
+		paths[i] = wr.path
+	}
+	d.commitLog = append(d.commitLog, commitLogEntry{version: d.version, paths: paths})
+
+	return nil
+}
+
+func (d *DB) rollback(w *WriteTransaction) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.active, w)
+	d.pruneCommitLog()
+}
+
+// hasConflict checks if any of the transactions commited after w was started
+// has written a path that was read or written by w.
+func (d *DB) hasConflict(w *WriteTransaction) bool {
+	for _, e := range d.commitLog {
+		if e.version <= w.base {
+			continue
+		}
+		for _, wp := range e.paths {
+			for _, rp := range w.reads {
+				if pathsOverlap(wp, rp) {
+					return true
+				}
+			}
+			for _, wr := range w.writes {
+				if pathsOverlap(wp, wr.path) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// pruneCommitLog removes entries that can't conflict with any of the
+// transactions in progress.
+func (d *DB) pruneCommitLog() {
+	oldest := d.version
+	for w := range d.active {
+		if w.base < oldest {
+			oldest = w.base
+		}
+	}
+
+	for len(d.commitLog) > 0 && d.commitLog[0].version <= oldest {
+		d.commitLog = d.commitLog[1:]
+	}
+}
+
+// ReadTransaction reads a snapshot of the last commited state of the database.
+type ReadTransaction struct {
+	root   *node
+	closed bool
+}
+
+var _ chaintrackdb.Reader = (*ReadTransaction)(nil)
+
+// Close ends the transaction.
+func (r *ReadTransaction) Close() {
+	r.closed = true
+}
+
+func (r *ReadTransaction) lookup(path string) (*node, error) {
+	if r.closed {
+		return nil, chaintrackdb.ErrTxClosed
+	}
+
+	parts, err := dbpath.Split(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return lookup(r.root, parts)
+}
+
+func (r *ReadTransaction) Get(path string) ([]byte, error) {
+	return get(r.lookup(path))
+}
+
+func (r *ReadTransaction) Exists(path string) (bool, error) {
+	return exists(r.lookup(path))
+}
+
+func (r *ReadTransaction) Count(path string) (uint64, error) {
+	return count(r.lookup(path))
+}
+
+// ForEachKey calls f with every key of the map at path, in the key order.
+func (r *ReadTransaction) ForEachKey(path string, f func(key string) error) error {
+	return forEachKey(f)(r.lookup(path))
+}
+
+func get(n *node, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	if n.isMap() {
+		return nil, chaintrackdb.ErrIsMap
+	}
+	return append([]byte{}, n.value...), nil
+}
+
+func exists(n *node, err error) (bool, error) {
+	if err == chaintrackdb.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func count(n *node, err error) (uint64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if !n.isMap() {
+		return 0, chaintrackdb.ErrNotMap
+	}
+	return uint64(len(n.children)), nil
+}
+
+func forEachKey(f func(key string) error) func(n *node, err error) error {
+	return func(n *node, err error) error {
+		if err != nil {
+			return err
+		}
+		if !n.isMap() {
+			return chaintrackdb.ErrNotMap
+		}
+		for _, k := range n.sortedKeys() {
+			err = f(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,171 @@
+package memdb_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/memdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// exercise runs the same operations through the ReadWriter interface
+// and returns what it has observed.
+func exercise(t *testing.T, tx chaintrackdb.ReadWriter) []interface{} {
+	observed := []interface{}{}
+	observe := func(v interface{}, err error) {
+		observed = append(observed, v, errors.Cause(err))
+	}
+
+	observe(nil, tx.CreateMap("m"))
+	observe(nil, tx.Put("m/a", []byte("abc")))
+	observe(nil, tx.Put("m/b", nil))
+	observe(nil, tx.PutAll("x/y/z", []byte("xyz")))
+	observe(nil, tx.Put("missing/a", nil))
+	observe(nil, tx.Put("m/a/b", nil))
+	observe(nil, tx.CreateMapAll("x/y"))
+	observe(nil, tx.CreateMapAll("x/y/z"))
+	observe(nil, tx.Delete("m/c"))
+	observe(nil, tx.Delete("x/y/z"))
+	observe(tx.Get("m/a"))
+	observe(tx.Get("m/b"))
+	observe(tx.Get("m"))
+	observe(tx.Get("m/c"))
+	observe(tx.Exists("m/a"))
+	observe(tx.Exists("m/c"))
+	observe(tx.Count(""))
+	observe(tx.Count("m"))
+	observe(tx.Count("m/a"))
+
+	keys := []string{}
+	err := tx.ForEachKey("", func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	observe(keys, err)
+
+	return observed
+}
+
+func TestMemDB(t *testing.T) {
+	ctx := context.Background()
+
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var expected []interface{}
+	err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		expected = exercise(t, tx)
+		return nil
+	})
+	require.NoError(t, err)
+
+	mdb := memdb.New()
+
+	t.Run("when I run the same operations on memdb", func(t *testing.T) {
+		var observed []interface{}
+		err = mdb.WriteTransaction(ctx, func(tx *memdb.WriteTransaction) error {
+			observed = exercise(t, tx)
+			return nil
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should get the same results as from the embedded database", func(t *testing.T) {
+			require.Equal(t, expected, observed)
+		})
+
+		t.Run("then the changes should be visible in a read transaction", func(t *testing.T) {
+			err = mdb.ReadTransaction(ctx, func(tx *memdb.ReadTransaction) error {
+				d, err := tx.Get("m/a")
+				require.NoError(t, err)
+				require.Equal(t, []byte("abc"), d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when two transactions change the same path", func(t *testing.T) {
+		tx1, err := mdb.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		_, err = tx1.Get("m/a")
+		require.NoError(t, err)
+
+		err = mdb.WriteTransaction(ctx, func(tx *memdb.WriteTransaction) error {
+			return tx.Put("m/a", []byte("def"))
+		})
+		require.NoError(t, err)
+
+		err = tx1.Put("m/a", []byte("ghi"))
+		require.NoError(t, err)
+
+		t.Run("then commiting the later one should fail with ErrConflict", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrConflict, tx1.Commit())
+		})
+	})
+
+	t.Run("when two transactions change different paths", func(t *testing.T) {
+		tx1, err := mdb.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx1.Put("m/b", []byte("b"))
+		require.NoError(t, err)
+
+		err = mdb.WriteTransaction(ctx, func(tx *memdb.WriteTransaction) error {
+			return tx.Put("m/a", []byte("jkl"))
+		})
+		require.NoError(t, err)
+
+		err = tx1.Commit()
+
+		t.Run("then both should be commited", func(t *testing.T) {
+			require.NoError(t, err)
+			err = mdb.ReadTransaction(ctx, func(tx *memdb.ReadTransaction) error {
+				d, err := tx.Get("m/a")
+				require.NoError(t, err)
+				require.Equal(t, []byte("jkl"), d)
+
+				d, err = tx.Get("m/b")
+				require.NoError(t, err)
+				require.Equal(t, []byte("b"), d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when a transaction is rolled back", func(t *testing.T) {
+		tx, err := mdb.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		err = tx.Put("rb", nil)
+		require.NoError(t, err)
+
+		err = tx.Rollback()
+		require.NoError(t, err)
+
+		t.Run("then using it should fail with ErrTxClosed", func(t *testing.T) {
+			_, err = tx.Exists("rb")
+			require.Equal(t, chaintrackdb.ErrTxClosed, err)
+		})
+
+		t.Run("then the changes should be discarded", func(t *testing.T) {
+			err = mdb.ReadTransaction(ctx, func(tx *memdb.ReadTransaction) error {
+				ex, err := tx.Exists("rb")
+				require.NoError(t, err)
+				require.False(t, ex)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+}
@@ -0,0 +1,120 @@
+package memdb
+
+import (
+	"sort"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/pkg/errors"
+)
+
+// node is an immutable value or map.
+// Changes create new nodes on the path from the root to the changed node.
+type node struct {
+	value []byte
+	// children is nil for values.
+	children map[string]*node
+}
+
+func emptyMap() *node {
+	return &node{children: map[string]*node{}}
+}
+
+func (n *node) isMap() bool {
+	return n.children != nil
+}
+
+// with returns a copy of the map with the key set to c, or removed if c is nil.
+func (n *node) with(key string, c *node) *node {
+	children := make(map[string]*node, len(n.children)+1)
+	for k, v := range n.children {
+		children[k] = v
+	}
+
+	if c == nil {
+		delete(children, key)
+	} else {
+		children[key] = c
+	}
+
+	return &node{children: children}
+}
+
+func (n *node) sortedKeys() []string {
+	keys := make([]string, 0, len(n.children))
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lookup returns the node at the path.
+func lookup(root *node, path []string) (*node, error) {
+	n := root
+	for _, p := range path {
+		if !n.isMap() {
+			return nil, chaintrackdb.ErrNotMap
+		}
+		c := n.children[p]
+		if c == nil {
+			return nil, chaintrackdb.ErrNotFound
+		}
+		n = c
+	}
+	return n, nil
+}
+
+// modify calls f with the map containing the last element of the path
+// and returns the root with the changed map.
+// If createParents is true, missing maps on the path are created.
+func modify(n *node, path []string, createParents bool, f func(m *node, key string) (*node, error)) (*node, error) {
+	if len(path) == 0 {
+		return nil, errors.New("attempted to modify parent of root")
+	}
+
+	if !n.isMap() {
+		return nil, chaintrackdb.ErrNotMap
+	}
+
+	if len(path) == 1 {
+		return f(n, path[0])
+	}
+
+	c := n.children[path[0]]
+	if c == nil && createParents {
+		c = emptyMap()
+	}
+	if c == nil {
+		return nil, chaintrackdb.ErrNotFound
+	}
+
+	nc, err := modify(c, path[1:], createParents, f)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.with(path[0], nc), nil
+}
+
+func checkKeySizes(parts []string) error {
+	for i, p := range parts {
+		if len(p) > btree.MaxKeySize {
+			return errors.Wrapf(chaintrackdb.ErrKeyTooLarge, "segment %d has %d bytes, max is %d", i, len(p), btree.MaxKeySize)
+		}
+	}
+	return nil
+}
+
+// pathsOverlap returns true if one of the paths is a prefix of the other one.
+func pathsOverlap(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	for i, p := range a {
+		if b[i] != p {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+package memdb
+
+import (
+	"context"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/pkg/errors"
+)
+
+// WriteTransaction is an optimistic transaction, see chaintrackdb.WriteTransaction.
+type WriteTransaction struct {
+	db     *DB
+	ctx    context.Context
+	base   uint64
+	root   *node
+	reads  [][]string
+	writes []write
+	closed bool
+}
+
+var _ chaintrackdb.ReadWriter = (*WriteTransaction)(nil)
+
+// write is a change made by the transaction that can be re-applied
+// to a different root.
+type write struct {
+	path  []string
+	apply func(root *node) (*node, error)
+}
+
+func (w *WriteTransaction) checkOpen() error {
+	if w.closed {
+		return chaintrackdb.ErrTxClosed
+	}
+	if w.ctx.Err() != nil {
+		w.close()
+		return chaintrackdb.ErrTxClosed
+	}
+	return nil
+}
+
+func (w *WriteTransaction) close() {
+	if !w.closed {
+		w.closed = true
+		w.db.rollback(w)
+	}
+}
+
+func (w *WriteTransaction) lookup(path string) (*node, error) {
+	err := w.checkOpen()
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := dbpath.Split(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w.reads = append(w.reads, parts)
+
+	return lookup(w.root, parts)
+}
+
+func (w *WriteTransaction) Get(path string) ([]byte, error) {
+	return get(w.lookup(path))
+}
+
+func (w *WriteTransaction) Exists(path string) (bool, error) {
+	return exists(w.lookup(path))
+}
+
+func (w *WriteTransaction) Count(path string) (uint64, error) {
+	return count(w.lookup(path))
+}
+
+// ForEachKey calls f with every key of the map at path, in the key order.
+func (w *WriteTransaction) ForEachKey(path string, f func(key string) error) error {
+	return forEachKey(f)(w.lookup(path))
+}
+
+func (w *WriteTransaction) Put(path string, d []byte) error {
+	return w.put(path, d, false)
+}
+
+// PutAll puts the value creating all missing maps on the path.
+func (w *WriteTransaction) PutAll(path string, d []byte) error {
+	return w.put(path, d, true)
+}
+
+func (w *WriteTransaction) put(path string, d []byte, createParents bool) error {
+	v := &node{value: append([]byte{}, d...)}
+	return w.modify(path, createParents, func(m *node, key string) (*node, error) {
+		return m.with(key, v), nil
+	})
+}
+
+func (w *WriteTransaction) CreateMap(path string) error {
+	return w.modify(path, false, func(m *node, key string) (*node, error) {
+		return m.with(key, emptyMap()), nil
+	})
+}
+
+// CreateMapAll creates the map and all missing maps on the path, like mkdir -p.
+// Existing maps are left as they are.
+func (w *WriteTransaction) CreateMapAll(path string) error {
+	return w.modify(path, true, func(m *node, key string) (*node, error) {
+		existing := m.children[key]
+		if existing == nil {
+			return m.with(key, emptyMap()), nil
+		}
+		if !existing.isMap() {
+			return nil, chaintrackdb.ErrNotMap
+		}
+		return m, nil
+	})
+}
+
+// Delete removes the value or the map with all its contents stored at path.
+func (w *WriteTransaction) Delete(path string) error {
+	return w.modify(path, false, func(m *node, key string) (*node, error) {
+		if m.children[key] == nil {
+			return nil, chaintrackdb.ErrNotFound
+		}
+		return m.with(key, nil), nil
+	})
+}
+
+// Commit commits the transaction.
+// Returns chaintrackdb.ErrConflict if a concurrently commited transaction has changed
+// any of the paths read or written by this transaction.
+func (w *WriteTransaction) Commit() error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	w.closed = true
+
+	return w.db.commit(w)
+}
+
+// Rollback discards all changes made by the transaction.
+// Rolling back a transaction that has already been closed is a no-op.
+func (w *WriteTransaction) Rollback() error {
+	w.close()
+	return nil
+}
+
+func (w *WriteTransaction) modify(path string, createParents bool, f func(m *node, key string) (*node, error)) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	parts, err := dbpath.Split(path)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", path)
+	}
+
+	err = checkKeySizes(parts)
+	if err != nil {
+		return err
+	}
+
+	wr := write{
+		path: parts,
+		apply: func(root *node) (*node, error) {
+			return modify(root, parts, createParents, f)
+		},
+	}
+
+	nr, err := wr.apply(w.root)
+	if err != nil {
+		return err
+	}
+
+	w.root = nr
+	w.writes = append(w.writes, wr)
+
+	return nil
+}
+
+// rebase re-applies all changes of the transaction onto the given root.
+func (w *WriteTransaction) rebase(root *node) (*node, error) {
+	var err error
+	for _, wr := range w.writes {
+		root, err = wr.apply(root)
+		if err == chaintrackdb.ErrNotFound || err == chaintrackdb.ErrNotMap {
+			return nil, chaintrackdb.ErrConflict
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "while rebasing write of %q", dbpath.Join(wr.path...))
+		}
+	}
+	return root, nil
+}
@@ -0,0 +1,284 @@
+package chaintrackdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	serrors "errors"
+	"io/ioutil"
+	"sort"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// MergeOperator folds operands into a value.
+// Merge must be associative: merging operands one by one or all at once
+// must give the same result.
+type MergeOperator struct {
+	// ValueType is the type of the value created by merging into a path without a value.
+	ValueType ValueType
+	// Merge returns the value after applying operands to existing.
+	// existing is nil if there is no value.
+	Merge func(existing []byte, operands [][]byte) ([]byte, error)
+}
+
+// CounterAdd adds 8 byte big endian unsigned integers, compatible with PutUint64 and GetUint64.
+var CounterAdd = MergeOperator{
+	ValueType: ValueTypeUint64,
+	Merge: func(existing []byte, operands [][]byte) ([]byte, error) {
+		var sum uint64
+		if existing != nil {
+			if len(existing) != 8 {
+				return nil, errors.New("malformed uint64 value")
+			}
+			sum = binary.BigEndian.Uint64(existing)
+		}
+		for _, o := range operands {
+			if len(o) != 8 {
+				return nil, errors.New("malformed uint64 operand")
+			}
+			sum += binary.BigEndian.Uint64(o)
+		}
+		res := make([]byte, 8)
+		binary.BigEndian.PutUint64(res, sum)
+		return res, nil
+	},
+}
+
+// Append appends operands to the value.
+var Append = MergeOperator{
+	ValueType: ValueTypeBytes,
+	Merge: func(existing []byte, operands [][]byte) ([]byte, error) {
+		res := append([]byte(nil), existing...)
+		for _, o := range operands {
+			res = append(res, o...)
+		}
+		return res, nil
+	},
+}
+
+// SetUnion merges JSON arrays of strings into a sorted JSON array of unique strings.
+var SetUnion = MergeOperator{
+	ValueType: ValueTypeJSON,
+	Merge: func(existing []byte, operands [][]byte) ([]byte, error) {
+		set := map[string]bool{}
+		for _, d := range append([][]byte{existing}, operands...) {
+			if d == nil {
+				continue
+			}
+			elements := []string{}
+			err := json.Unmarshal(d, &elements)
+			if err != nil {
+				return nil, errors.Wrap(err, "while decoding set")
+			}
+			for _, e := range elements {
+				set[e] = true
+			}
+		}
+
+		res := make([]string, 0, len(set))
+		for e := range set {
+			res = append(res, e)
+		}
+		sort.Strings(res)
+
+		return json.Marshal(res)
+	},
+}
+
+// ErrNoMergeOperator is returned when merging into a path without a registered merge operator.
+var ErrNoMergeOperator = serrors.New("no merge operator registered")
+
+// ErrMergeOperatorExists is returned when registering a merge operator with a name that is already used.
+var ErrMergeOperatorExists = serrors.New("merge operator already registered")
+
+// foldThreshold is the number of operands kept in a merge node before they are folded into the value.
+// It is lower than data.MaxMergeOperands, the most operands a merge node can store,
+// to keep reads of merged values cheap.
+const foldThreshold = 16
+
+type mergeOperator struct {
+	name   string
+	prefix []string
+	op     MergeOperator
+}
+
+type mergeOperators map[string]*mergeOperator
+
+// RegisterMergeOperator registers the merge operator for all paths under prefix.
+// The name is stored with the merged values, so the operator has to be registered
+// under the same name every time the database is opened.
+// Transactions started before the registration don't use the operator.
+func (d *DB) RegisterMergeOperator(name, prefix string, op MergeOperator) error {
+	pp, err := dbpath.Split(prefix)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", prefix)
+	}
+
+	d.mergeMu.Lock()
+	defer d.mergeMu.Unlock()
+
+	if d.mergeOperators[name] != nil {
+		return ErrMergeOperatorExists
+	}
+
+	d.mergeOperators[name] = &mergeOperator{name: name, prefix: pp, op: op}
+
+	return nil
+}
+
+// operatorFor returns the operator with the longest prefix of the path.
+func (m mergeOperators) operatorFor(path []string) *mergeOperator {
+	var found *mergeOperator
+	for _, o := range m {
+		if len(o.prefix) > len(path) || !pathsOverlap(o.prefix, path) {
+			continue
+		}
+		if found == nil || len(o.prefix) > len(found.prefix) {
+			found = o
+		}
+	}
+	return found
+}
+
+// Merge merges the operand into the value at path using the operator registered for the path.
+// Operands are kept next to the value and folded into it when the value is read,
+// or when there are too many of them.
+// Merges are not conflicting with concurrent transactions, they are re-applied on commit.
+func (w *WriteTransaction) Merge(path string, operand []byte) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	pth, err := dbpath.Split(path)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", path)
+	}
+
+	err = checkKeySizes(pth)
+	if err != nil {
+		return err
+	}
+
+	mo := w.mergeOperators.operatorFor(pth)
+	if mo == nil {
+		return ErrNoMergeOperator
+	}
+
+	oa, err := data.StoreData(w.swt, operand, dataSegSize, dataFanout)
+	if err != nil {
+		return errors.Wrap(err, "while storing operand")
+	}
+
+	return w.withIndexes(pth, func() error {
+		return w.applyWrite(write{
+			path:  pth,
+			merge: true,
+			apply: func(root store.Address) (store.Address, error) {
+				return modifyPath(w.swt, root, pth, false, func(ad store.Address, key string) (store.Address, error) {
+					existing, err := btree.Get(w.swt, ad, []byte(key))
+					if err == btree.ErrNotFound {
+						existing = store.NilAddress
+					} else if err != nil {
+						return store.NilAddress, err
+					}
+
+					na, err := w.mergeInto(existing, mo, oa)
+					if err != nil {
+						return store.NilAddress, err
+					}
+
+					return btree.Put(w.swt, ad, []byte(key), na)
+				})
+			},
+		})
+	})
+}
+
+// mergeInto returns the address of a merge node with the operand merged into the existing value.
+func (w *WriteTransaction) mergeInto(existing store.Address, mo *mergeOperator, operand store.Address) (store.Address, error) {
+	if existing == store.NilAddress {
+		return data.StoreMergeNode(w.swt, mo.name, store.NilAddress, []store.Address{operand})
+	}
+
+	br, err := w.swt.GetBlock(existing)
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while reading existing value")
+	}
+
+	if br.Type() == store.TypeBTreeNode {
+		return store.NilAddress, ErrIsMap
+	}
+
+	if br.Type() != store.TypeMergeNode {
+		return data.StoreMergeNode(w.swt, mo.name, existing, []store.Address{operand})
+	}
+
+	name, base, operands, err := data.MergeNode(w.swt, existing)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	if name == mo.name && len(operands) < foldThreshold {
+		return data.StoreMergeNode(w.swt, mo.name, base, append(operands, operand))
+	}
+
+	// fold the existing operands into a new base value
+	vt, d, err := readValue(w.swt, w.mergeOperators, existing)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	folded, err := storeValue(w.swt, vt, d)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	return data.StoreMergeNode(w.swt, mo.name, folded, []store.Address{operand})
+}
+
+// foldMergeNode returns the value of the merge node at addr.
+func foldMergeNode(r store.Reader, ops mergeOperators, addr store.Address) (ValueType, []byte, error) {
+	name, base, operands, err := data.MergeNode(r, addr)
+	if err != nil {
+		return ValueTypeBytes, nil, err
+	}
+
+	mo := ops[name]
+	if mo == nil {
+		return ValueTypeBytes, nil, errors.Wrapf(ErrNoMergeOperator, "operator %q", name)
+	}
+
+	vt := mo.op.ValueType
+	var existing []byte
+
+	if base != store.NilAddress {
+		vt, existing, err = readValue(r, ops, base)
+		if err != nil {
+			return ValueTypeBytes, nil, errors.Wrap(err, "while reading base value")
+		}
+	}
+
+	ods := make([][]byte, len(operands))
+	for i, oa := range operands {
+		dr, err := data.NewReader(oa, r)
+		if err != nil {
+			return ValueTypeBytes, nil, errors.Wrap(err, "while creating operand reader")
+		}
+		ods[i], err = ioutil.ReadAll(dr)
+		if err != nil {
+			return ValueTypeBytes, nil, errors.Wrap(err, "while reading operand")
+		}
+	}
+
+	d, err := mo.op.Merge(existing, ods)
+	if err != nil {
+		return ValueTypeBytes, nil, errors.Wrapf(err, "while merging with operator %q", name)
+	}
+
+	return vt, d, nil
+}
@@ -0,0 +1,162 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func uint64Bytes(v uint64) []byte {
+	d := make([]byte, 8)
+	binary.BigEndian.PutUint64(d, v)
+	return d
+}
+
+func TestMerge(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, db.RegisterMergeOperator("counter", "counters", chaintrackdb.CounterAdd))
+	require.NoError(t, db.RegisterMergeOperator("log", "logs", chaintrackdb.Append))
+	require.NoError(t, db.RegisterMergeOperator("tags", "tags", chaintrackdb.SetUnion))
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		for _, m := range []string{"counters", "logs", "tags", "other"} {
+			err := tx.CreateMap(m)
+			require.NoError(t, err)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	t.Run("when I register an operator with a name that is already used", func(t *testing.T) {
+		err = db.RegisterMergeOperator("counter", "other", chaintrackdb.CounterAdd)
+		t.Run("then I should get ErrMergeOperatorExists", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrMergeOperatorExists, err)
+		})
+	})
+
+	t.Run("when I merge into a counter more times than operands fit into a merge node", func(t *testing.T) {
+		for i := 0; i < 40; i++ {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Merge("counters/c1", uint64Bytes(2))
+			})
+			require.NoError(t, err)
+		}
+
+		t.Run("then the folded value should be the sum of all operands", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				v, err := tx.GetUint64("counters/c1")
+				require.NoError(t, err)
+				require.Equal(t, uint64(80), v)
+
+				st, err := tx.Stat("counters/c1")
+				require.NoError(t, err)
+				require.Equal(t, chaintrackdb.ValueTypeUint64, st.ValueType)
+				require.Equal(t, uint64(8), st.Size)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when two concurrent transactions merge into the same counter", func(t *testing.T) {
+		tx1, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+		tx2, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		require.NoError(t, tx1.Merge("counters/c2", uint64Bytes(3)))
+		require.NoError(t, tx2.Merge("counters/c2", uint64Bytes(4)))
+
+		require.NoError(t, tx1.Commit())
+
+		t.Run("then both should be commited", func(t *testing.T) {
+			require.NoError(t, tx2.Commit())
+
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				v, err := tx.GetUint64("counters/c2")
+				require.NoError(t, err)
+				require.Equal(t, uint64(7), v)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I merge into an existing value", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			err := tx.PutUint64("counters/c3", 10)
+			require.NoError(t, err)
+			return tx.Merge("counters/c3", uint64Bytes(5))
+		})
+		require.NoError(t, err)
+
+		t.Run("then the operand should be applied to the value", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				v, err := tx.GetUint64("counters/c3")
+				require.NoError(t, err)
+				require.Equal(t, uint64(15), v)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I append and union", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			require.NoError(t, tx.Merge("logs/l1", []byte("a")))
+			require.NoError(t, tx.Merge("logs/l1", []byte("b")))
+			require.NoError(t, tx.Merge("tags/t1", []byte(`["y","x"]`)))
+			return tx.Merge("tags/t1", []byte(`["x","z"]`))
+		})
+		require.NoError(t, err)
+
+		t.Run("then the values should be merged", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("logs/l1")
+				require.NoError(t, err)
+				require.Equal(t, []byte("ab"), d)
+
+				tags := []string{}
+				err = tx.GetJSON("tags/t1", &tags)
+				require.NoError(t, err)
+				require.Equal(t, []string{"x", "y", "z"}, tags)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I merge into a path without an operator", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Merge("other/x", []byte{1})
+		})
+		t.Run("then I should get ErrNoMergeOperator", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrNoMergeOperator, err)
+		})
+	})
+
+	t.Run("when I merge into a map", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.Merge("counters", uint64Bytes(1))
+		})
+		t.Run("then I should get an error", func(t *testing.T) {
+			require.Error(t, err)
+		})
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			err := tx.CreateMap("counters/m")
+			require.NoError(t, err)
+			return tx.Merge("counters/m", uint64Bytes(1))
+		})
+		t.Run("then I should get ErrIsMap", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrIsMap, errors.Cause(err))
+		})
+	})
+}
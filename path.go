@@ -0,0 +1,51 @@
+package chaintrackdb
+
+import "github.com/draganm/chaintrackdb/dbpath"
+
+// The *Path variants of the methods accept a structured path
+// so keys can contain arbitrary bytes, including empty keys.
+
+// GetPath is Get for a structured path.
+func (w *WriteTransaction) GetPath(p dbpath.Path) ([]byte, error) {
+	return w.Get(p.String())
+}
+
+// PutPath is Put for a structured path.
+func (w *WriteTransaction) PutPath(p dbpath.Path, d []byte) error {
+	return w.Put(p.String(), d)
+}
+
+// PutAllPath is PutAll for a structured path.
+func (w *WriteTransaction) PutAllPath(p dbpath.Path, d []byte) error {
+	return w.PutAll(p.String(), d)
+}
+
+// CreateMapPath is CreateMap for a structured path.
+func (w *WriteTransaction) CreateMapPath(p dbpath.Path) error {
+	return w.CreateMap(p.String())
+}
+
+// CreateMapAllPath is CreateMapAll for a structured path.
+func (w *WriteTransaction) CreateMapAllPath(p dbpath.Path) error {
+	return w.CreateMapAll(p.String())
+}
+
+// DeletePath is Delete for a structured path.
+func (w *WriteTransaction) DeletePath(p dbpath.Path) error {
+	return w.Delete(p.String())
+}
+
+// ExistsPath is Exists for a structured path.
+func (w *WriteTransaction) ExistsPath(p dbpath.Path) (bool, error) {
+	return w.Exists(p.String())
+}
+
+// CountPath is Count for a structured path.
+func (w *WriteTransaction) CountPath(p dbpath.Path) (uint64, error) {
+	return w.Count(p.String())
+}
+
+// StatPath is Stat for a structured path.
+func (w *WriteTransaction) StatPath(p dbpath.Path) (Stat, error) {
+	return w.Stat(p.String())
+}
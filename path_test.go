@@ -0,0 +1,73 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryKeys(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	m := dbpath.Path{[]byte{0, '/', 255}}
+	empty := m.Append([]byte{})
+	dot := m.Append([]byte(".."))
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.CreateMapPath(m)
+		require.NoError(t, err)
+
+		err = tx.PutPath(empty, []byte{1})
+		require.NoError(t, err)
+
+		return tx.PutPath(dot, []byte{2})
+	})
+	require.NoError(t, err)
+
+	t.Run("when I read values with binary keys", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			cnt, err := tx.CountPath(m)
+			require.NoError(t, err)
+			require.Equal(t, uint64(2), cnt)
+
+			d, err := tx.GetPath(empty)
+			require.NoError(t, err)
+			require.Equal(t, []byte{1}, d)
+
+			d, err = tx.GetPath(dot)
+			require.NoError(t, err)
+			require.Equal(t, []byte{2}, d)
+
+			t.Run("then the escaped string path should address the same value", func(t *testing.T) {
+				d, err = tx.Get(empty.String())
+				require.NoError(t, err)
+				require.Equal(t, []byte{1}, d)
+			})
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("when I delete a value with an empty key", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			return tx.DeletePath(empty)
+		})
+		require.NoError(t, err)
+
+		t.Run("then it should not exist", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				ex, err := tx.ExistsPath(empty)
+				require.NoError(t, err)
+				require.False(t, ex)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package chaintrackdb
+
+import (
+	"context"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/dbpath"
+	"github.com/draganm/chaintrackdb/store"
+)
+
+// ReadTransaction reads a snapshot of the last commited state of the database.
+// Read transactions never conflict and are the only transactions
+// available in a database opened with OpenReadOnly.
+type ReadTransaction struct {
+	rtx            *store.ReadTransaction
+	root           store.Address
+	mergeOperators mergeOperators
+	closed         bool
+}
+
+// NewReadTransaction starts a read transaction.
+// Close must be called when the transaction is not used anymore.
+func (d *DB) NewReadTransaction() *ReadTransaction {
+	rtx, root := d.s.NewPinnedReadTransaction()
+
+	d.mergeMu.RLock()
+	defer d.mergeMu.RUnlock()
+
+	ops := make(mergeOperators, len(d.mergeOperators))
+	for n, o := range d.mergeOperators {
+		ops[n] = o
+	}
+
+	return &ReadTransaction{
+		rtx:            rtx,
+		root:           root,
+		mergeOperators: ops,
+	}
+}
+
+// ReadTransaction runs f in a new read transaction.
+func (d *DB) ReadTransaction(ctx context.Context, f func(tx *ReadTransaction) error) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	tx := d.NewReadTransaction()
+	defer tx.Close()
+
+	return f(tx)
+}
+
+// Close ends the transaction.
+func (r *ReadTransaction) Close() {
+	r.closed = true
+	r.rtx.Close()
+}
+
+func (r *ReadTransaction) pathElementAddress(path string) (store.Address, error) {
+	if r.closed {
+		return store.NilAddress, ErrTxClosed
+	}
+
+	parts, err := dbpath.Split(path)
+	if err != nil {
+		return store.NilAddress, err
+	}
+
+	return pathElementAddress(r.rtx, r.root, parts)
+}
+
+func (r *ReadTransaction) Get(path string) ([]byte, error) {
+	addr, err := r.pathElementAddress(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, d, err := readValue(r.rtx, r.mergeOperators, addr)
+	return d, err
+}
+
+func (r *ReadTransaction) Exists(path string) (bool, error) {
+	_, err := r.pathElementAddress(path)
+
+	if err == ErrNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *ReadTransaction) Count(path string) (uint64, error) {
+	addr, err := r.pathElementAddress(path)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt, err := btree.Count(r.rtx, addr)
+	if err == btree.ErrNotBTreeNode {
+		return 0, ErrNotMap
+	}
+
+	return cnt, err
+}
+
+// Stat returns information about the element stored at path.
+func (r *ReadTransaction) Stat(path string) (Stat, error) {
+	addr, err := r.pathElementAddress(path)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	return stat(r.rtx, r.mergeOperators, addr)
+}
+
+// ForEachKey calls f with every key of the map at path, in the key order.
+func (r *ReadTransaction) ForEachKey(path string, f func(key string) error) error {
+	addr, err := r.pathElementAddress(path)
+	if err != nil {
+		return err
+	}
+
+	return forEachKey(r.rtx, addr, f)
+}
+
+func forEachKey(r store.Reader, addr store.Address, f func(key string) error) error {
+	err := btree.ForEach(r, addr, func(key []byte, value store.Address) error {
+		return f(string(key))
+	})
+	if err == btree.ErrNotBTreeNode {
+		return ErrNotMap
+	}
+	return err
+}
+
+// ErrReadOnly is returned when starting a write transaction in a database opened with OpenReadOnly.
+var ErrReadOnly = store.ErrReadOnly
@@ -0,0 +1,156 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTransaction(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		require.NoError(t, tx.PutAll("m/b", []byte{2}))
+		require.NoError(t, tx.PutAll("m/a", []byte{1}))
+		return tx.CreateMap("m/c")
+	})
+	require.NoError(t, err)
+
+	t.Run("when I start a read transaction", func(t *testing.T) {
+		tx := db.NewReadTransaction()
+		defer tx.Close()
+
+		t.Run("and a write transaction is commited", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Delete("m")
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then the read transaction should see the state from when it was started", func(t *testing.T) {
+			d, err := tx.Get("m/a")
+			require.NoError(t, err)
+			require.Equal(t, []byte{1}, d)
+
+			ex, err := tx.Exists("m/c")
+			require.NoError(t, err)
+			require.True(t, ex)
+
+			cnt, err := tx.Count("m")
+			require.NoError(t, err)
+			require.Equal(t, uint64(3), cnt)
+
+			st, err := tx.Stat("m/c")
+			require.NoError(t, err)
+			require.Equal(t, chaintrackdb.KindMap, st.Kind)
+
+			keys := []string{}
+			err = tx.ForEachKey("m", func(key string) error {
+				keys = append(keys, key)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, []string{"a", "b", "c"}, keys)
+		})
+
+		t.Run("then listing keys of a value should return ErrNotMap", func(t *testing.T) {
+			err = tx.ForEachKey("m/a", func(key string) error {
+				return nil
+			})
+			require.Equal(t, chaintrackdb.ErrNotMap, err)
+		})
+
+		t.Run("when I close the transaction", func(t *testing.T) {
+			tx.Close()
+			t.Run("then reading should fail", func(t *testing.T) {
+				_, err = tx.Get("m/a")
+				require.Equal(t, chaintrackdb.ErrTxClosed, err)
+			})
+		})
+	})
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+
+	err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		return tx.Put("abc", []byte{1, 2, 3})
+	})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	t.Run("when I open the database read only", func(t *testing.T) {
+		db, err := chaintrackdb.OpenReadOnly(td)
+		require.NoError(t, err)
+		defer db.Close()
+
+		t.Run("then I should be able to read values", func(t *testing.T) {
+			err = db.ReadTransaction(ctx, func(tx *chaintrackdb.ReadTransaction) error {
+				d, err := tx.Get("abc")
+				require.NoError(t, err)
+				require.Equal(t, []byte{1, 2, 3}, d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then write transactions should fail with ErrReadOnly", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("def", []byte{1})
+			})
+			require.Equal(t, chaintrackdb.ErrReadOnly, errors.Cause(err))
+		})
+
+		t.Run("then compacting should fail with ErrReadOnly", func(t *testing.T) {
+			require.Equal(t, chaintrackdb.ErrReadOnly, errors.Cause(db.Compact()))
+		})
+	})
+}
+
+func TestCompact(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	for i := 0; i < 50; i++ {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			_, err := tx.Increment("counter", 1)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	t.Run("when I compact the database", func(t *testing.T) {
+		err := db.Compact()
+		require.NoError(t, err)
+
+		t.Run("then there should be no garbage", func(t *testing.T) {
+			stats, err := db.Stats()
+			require.NoError(t, err)
+			require.Equal(t, stats.LiveBytes, stats.OccupiedBytes)
+		})
+
+		t.Run("then the data should be preserved", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				v, err := tx.GetUint64("counter")
+				require.NoError(t, err)
+				require.Equal(t, uint64(50), v)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package chaintrackdb
+
+import (
+	"context"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// replicationHello is sent by the follower when it connects to the leader.
+type replicationHello struct {
+	// From is the address of the next block the follower expects.
+	From store.Address
+}
+
+// ServeReplication streams all commits of the database to the follower connected over rw
+// until ctx is done or the follower disconnects.
+// The follower is first brought up to date with the last commited root.
+// rw is closed when ServeReplication returns.
+func (d *DB) ServeReplication(ctx context.Context, rw io.ReadWriteCloser) error {
+	defer rw.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := closeOnDone(ctx, rw)
+	defer stop()
+
+	hello := replicationHello{}
+	err := gob.NewDecoder(rw).Decode(&hello)
+	if err != nil {
+		return errors.Wrap(err, "while reading replication hello")
+	}
+
+	// the follower doesn't send anything after the hello, reading fails once it disconnects
+	// or rw is closed when returning
+	go func() {
+		io.Copy(ioutil.Discard, rw)
+		cancel()
+	}()
+
+	enc := gob.NewEncoder(rw)
+
+	err = d.s.Replicate(ctx, hello.From, func(f store.Frame) error {
+		return enc.Encode(f)
+	})
+	if err != nil {
+		return errors.Wrap(err, "while replicating")
+	}
+
+	return nil
+}
+
+// Follow applies commits streamed by ServeReplication of the leader connected over rw
+// until ctx is done or the leader disconnects.
+// Once following, the database is read only: write transactions fail with ErrReadOnly.
+// The database directory must not be written by anything but replication from the same leader.
+// rw is closed when Follow returns.
+func (d *DB) Follow(ctx context.Context, rw io.ReadWriteCloser) error {
+	defer rw.Close()
+
+	stop := closeOnDone(ctx, rw)
+	defer stop()
+
+	err := gob.NewEncoder(rw).Encode(replicationHello{From: d.s.NextAddress()})
+	if err != nil {
+		return errors.Wrap(err, "while sending replication hello")
+	}
+
+	dec := gob.NewDecoder(rw)
+
+	for {
+		f := store.Frame{}
+		err = dec.Decode(&f)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return errors.Wrap(err, "while reading replication frame")
+		}
+
+		err = d.s.ApplyFrame(f)
+		if err != nil {
+			return errors.Wrap(err, "while applying replication frame")
+		}
+	}
+}
+
+// closeOnDone closes c when ctx is done, unblocking pending reads and writes.
+// The returned function stops watching ctx.
+func closeOnDone(ctx context.Context, c io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,126 @@
+package chaintrackdb_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplication(t *testing.T) {
+	leader, cleanupLeader := NewEmptyDB(t)
+	defer cleanupLeader()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	put := func(t *testing.T, from, to int) {
+		for i := from; i < to; i++ {
+			err := leader.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.PutAll(fmt.Sprintf("m/%d", i%7), make([]byte, 500*i))
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	export := func(t *testing.T, db *chaintrackdb.DB) []byte {
+		buf := new(bytes.Buffer)
+		err := db.Export(ctx, "", buf, chaintrackdb.ExportJSONLines)
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	follow := func(t *testing.T, follower *chaintrackdb.DB) (chan error, chan error) {
+		lc, fc := net.Pipe()
+		served := make(chan error, 1)
+		followed := make(chan error, 1)
+		go func() {
+			served <- leader.ServeReplication(ctx, lc)
+		}()
+		go func() {
+			followed <- follower.Follow(ctx, fc)
+		}()
+		return served, followed
+	}
+
+	waitForLeader := func(t *testing.T, follower *chaintrackdb.DB) {
+		expected := export(t, leader)
+		require.Eventually(t, func() bool {
+			return bytes.Equal(expected, export(t, follower))
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	put(t, 0, 30)
+
+	fd, cleanupFollowerDir := NewTempDir(t)
+	defer cleanupFollowerDir()
+
+	follower, err := chaintrackdb.Open(fd)
+	require.NoError(t, err)
+
+	t.Run("when a follower connects to the leader", func(t *testing.T) {
+		served, followed := follow(t, follower)
+
+		t.Run("then the follower should catch up with the leader", func(t *testing.T) {
+			waitForLeader(t, follower)
+		})
+
+		t.Run("when the leader commits and compacts", func(t *testing.T) {
+			put(t, 30, 60)
+			err = leader.Compact()
+			require.NoError(t, err)
+			put(t, 60, 70)
+
+			t.Run("then the follower should apply the commits", func(t *testing.T) {
+				waitForLeader(t, follower)
+			})
+		})
+
+		t.Run("then writing to the follower should fail", func(t *testing.T) {
+			err = follower.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("x", nil)
+			})
+			require.Equal(t, chaintrackdb.ErrReadOnly, errors.Cause(err))
+		})
+
+		t.Run("when the follower disconnects", func(t *testing.T) {
+			cancel()
+
+			t.Run("then both sides should stop", func(t *testing.T) {
+				require.Error(t, <-followed)
+				require.Error(t, <-served)
+			})
+		})
+	})
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("when the follower reconnects after it was reopened", func(t *testing.T) {
+		err = follower.Close()
+		require.NoError(t, err)
+
+		follower, err = chaintrackdb.Open(fd)
+		require.NoError(t, err)
+		defer follower.Close()
+
+		follow(t, follower)
+		put(t, 70, 80)
+
+		t.Run("then the follower should catch up with the leader", func(t *testing.T) {
+			waitForLeader(t, follower)
+		})
+
+		t.Run("then the follower dir should pass the check", func(t *testing.T) {
+			report, err := chaintrackdb.Check(fd, false)
+			require.NoError(t, err)
+			require.Empty(t, report.Problems)
+		})
+	})
+}
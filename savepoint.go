@@ -0,0 +1,89 @@
+package chaintrackdb
+
+import (
+	serrors "errors"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSavepoint is returned when rolling back to a savepoint that
+// belongs to another transaction or has been discarded by rolling back
+// to an earlier savepoint.
+var ErrInvalidSavepoint = serrors.New("invalid savepoint")
+
+// Savepoint is a state of a write transaction that can be restored with RollbackTo.
+type Savepoint struct {
+	tx     *WriteTransaction
+	id     uint64
+	root   store.Address
+	writes int
+	mark   store.Address
+}
+
+// Savepoint marks the current state of the transaction.
+func (w *WriteTransaction) Savepoint() (Savepoint, error) {
+	err := w.checkOpen()
+	if err != nil {
+		return Savepoint{}, err
+	}
+
+	mark, err := w.swt.Savepoint()
+	if err != nil {
+		return Savepoint{}, err
+	}
+
+	w.lastSavepointID++
+
+	sp := Savepoint{
+		tx:     w,
+		id:     w.lastSavepointID,
+		root:   w.root,
+		writes: len(w.writes),
+		mark:   mark,
+	}
+
+	w.savepoints = append(w.savepoints, sp.id)
+
+	return sp, nil
+}
+
+// RollbackTo discards all changes made after the savepoint was created.
+// Savepoints created after sp are discarded, sp itself can be used again.
+// Blocks written after the savepoint are truncated from the transaction's
+// scratch segment, so rolling back is cheap.
+//
+// Paths read after the savepoint are still checked for conflicts on commit.
+func (w *WriteTransaction) RollbackTo(sp Savepoint) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	if sp.tx != w {
+		return ErrInvalidSavepoint
+	}
+
+	idx := -1
+	for i, id := range w.savepoints {
+		if id == sp.id {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		return ErrInvalidSavepoint
+	}
+
+	err = w.swt.RollbackTo(sp.mark)
+	if err != nil {
+		return errors.Wrap(err, "while truncating transaction blocks")
+	}
+
+	w.savepoints = w.savepoints[:idx+1]
+	w.root = sp.root
+	w.writes = w.writes[:sp.writes]
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSavepoints(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	t.Run("when I roll back to a savepoint", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			err := tx.Put("abc", []byte{1})
+			require.NoError(t, err)
+
+			sp, err := tx.Savepoint()
+			require.NoError(t, err)
+
+			err = tx.Put("def", []byte{2})
+			require.NoError(t, err)
+
+			err = tx.Put("abc", []byte{3})
+			require.NoError(t, err)
+
+			sp2, err := tx.Savepoint()
+			require.NoError(t, err)
+
+			err = tx.RollbackTo(sp)
+			require.NoError(t, err)
+
+			t.Run("then later savepoints should be invalid", func(t *testing.T) {
+				err = tx.RollbackTo(sp2)
+				require.Equal(t, chaintrackdb.ErrInvalidSavepoint, err)
+			})
+
+			t.Run("then the savepoint can be used again", func(t *testing.T) {
+				err = tx.Put("ghi", []byte{4})
+				require.NoError(t, err)
+
+				err = tx.RollbackTo(sp)
+				require.NoError(t, err)
+			})
+
+			return tx.Put("jkl", []byte{5})
+		})
+		require.NoError(t, err)
+
+		t.Run("then only changes before the savepoint should be commited", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("abc")
+				require.NoError(t, err)
+				require.Equal(t, []byte{1}, d)
+
+				for _, p := range []string{"def", "ghi"} {
+					exists, err := tx.Exists(p)
+					require.NoError(t, err)
+					require.False(t, exists)
+				}
+
+				d, err = tx.Get("jkl")
+				require.NoError(t, err)
+				require.Equal(t, []byte{5}, d)
+
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I roll back to a savepoint of another transaction", func(t *testing.T) {
+		tx1, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+		defer tx1.Rollback()
+
+		tx2, err := db.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+		defer tx2.Rollback()
+
+		sp, err := tx1.Savepoint()
+		require.NoError(t, err)
+
+		t.Run("then ErrInvalidSavepoint should be returned", func(t *testing.T) {
+			err = tx2.RollbackTo(sp)
+			require.Equal(t, chaintrackdb.ErrInvalidSavepoint, err)
+		})
+	})
+
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+)
+
+// Operations of a batch.
+const (
+	OpGet          = "get"
+	OpPut          = "put"
+	OpPutAll       = "putAll"
+	OpCreateMap    = "createMap"
+	OpCreateMapAll = "createMapAll"
+	OpDelete       = "delete"
+	OpExists       = "exists"
+	OpCount        = "count"
+	OpKeys         = "keys"
+)
+
+// Op is an operation of a batch.
+type Op struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// Result is the result of an operation of a batch.
+// Only the field matching the operation is set.
+type Result struct {
+	Value  []byte   `json:"value,omitempty"`
+	Exists bool     `json:"exists,omitempty"`
+	Count  uint64   `json:"count,omitempty"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+// BatchRequest is the body of a batch request.
+type BatchRequest struct {
+	Ops []Op `json:"ops"`
+}
+
+// BatchResponse is the body of a successful batch response.
+type BatchResponse struct {
+	Results []Result `json:"results"`
+}
+
+// TransactionResponse is the body of the response to starting a transaction.
+type TransactionResponse struct {
+	ID string `json:"id"`
+}
+
+// ErrorResponse is the body of all unsuccessful responses.
+type ErrorResponse struct {
+	// Code identifies the error, see ErrorForCode.
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Op is the index of the failed operation of a batch.
+	Op int `json:"op"`
+}
+
+// Error codes of errors returned by transactions.
+const (
+	CodeNotFound    = "not_found"
+	CodeIsMap       = "is_map"
+	CodeNotMap      = "not_map"
+	CodeConflict    = "conflict"
+	CodeTxClosed    = "tx_closed"
+	CodeReadOnly    = "read_only"
+	CodeKeyTooLarge = "key_too_large"
+	CodeBadRequest  = "bad_request"
+	CodeTooLarge    = "too_large"
+	CodeInternal    = "internal"
+)
+
+var errorCodes = []struct {
+	err    error
+	code   string
+	status int
+}{
+	{chaintrackdb.ErrNotFound, CodeNotFound, http.StatusNotFound},
+	{chaintrackdb.ErrIsMap, CodeIsMap, http.StatusConflict},
+	{chaintrackdb.ErrNotMap, CodeNotMap, http.StatusConflict},
+	{chaintrackdb.ErrConflict, CodeConflict, http.StatusConflict},
+	{chaintrackdb.ErrTxClosed, CodeTxClosed, http.StatusGone},
+	{chaintrackdb.ErrReadOnly, CodeReadOnly, http.StatusForbidden},
+	{chaintrackdb.ErrKeyTooLarge, CodeKeyTooLarge, http.StatusBadRequest},
+}
+
+// ErrorForCode returns the chaintrackdb error with the code,
+// nil if the code doesn't belong to any of them.
+func ErrorForCode(code string) error {
+	for _, ec := range errorCodes {
+		if ec.code == code {
+			return ec.err
+		}
+	}
+	return nil
+}
+
+// errValueTooLarge marks values larger than the server's MaxValueSize.
+var errValueTooLarge = errors.New("value too large")
+
+// errBadRequest marks errors caused by malformed requests.
+var errBadRequest = errors.New("bad request")
+
+func errorResponse(err error, op int) (int, ErrorResponse) {
+	cause := errors.Cause(err)
+
+	for _, ec := range errorCodes {
+		if cause == ec.err {
+			return ec.status, ErrorResponse{Code: ec.code, Message: err.Error(), Op: op}
+		}
+	}
+
+	if cause == errValueTooLarge {
+		return http.StatusRequestEntityTooLarge, ErrorResponse{Code: CodeTooLarge, Message: err.Error(), Op: op}
+	}
+
+	if cause == errBadRequest {
+		return http.StatusBadRequest, ErrorResponse{Code: CodeBadRequest, Message: err.Error(), Op: op}
+	}
+
+	return http.StatusInternalServerError, ErrorResponse{Code: CodeInternal, Message: err.Error(), Op: op}
+}
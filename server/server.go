@@ -0,0 +1,352 @@
+// Package server exposes a chaintrackdb database over HTTP with JSON encoded requests.
+//
+// Endpoints:
+//
+//	POST /batch                           run a batch of operations in one transaction
+//	POST /transactions                    start a write transaction
+//	POST /transactions/<id>/ops           run a batch of operations in the transaction
+//	GET  /transactions/<id>/value?path=   read the value as the response body
+//	PUT  /transactions/<id>/value?path=   store the request body, &all=true creates missing maps
+//	POST /transactions/<id>/commit        commit the transaction
+//	POST /transactions/<id>/rollback      roll back the transaction
+//
+// Values read and written through the value endpoint are not encoded
+// and are streamed without being held in memory.
+// Errors are returned as ErrorResponse.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+)
+
+// DefaultTransactionTimeout is the default TransactionTimeout of a Server.
+const DefaultTransactionTimeout = time.Minute
+
+// DefaultMaxValueSize is the default MaxValueSize of a Server.
+const DefaultMaxValueSize = 1 << 30
+
+// Server serves a database over HTTP.
+type Server struct {
+	db *chaintrackdb.DB
+
+	// TransactionTimeout is the time after the last request of a transaction
+	// when the transaction is rolled back.
+	// Default value is DefaultTransactionTimeout.
+	TransactionTimeout time.Duration
+
+	// MaxValueSize is the maximal size of a value put through the value endpoint.
+	// Default value is DefaultMaxValueSize.
+	MaxValueSize int64
+
+	mu     *sync.Mutex
+	txs    map[string]*session
+	nextID uint64
+}
+
+// session is a write transaction started by a client.
+type session struct {
+	mu     *sync.Mutex
+	tx     *chaintrackdb.WriteTransaction
+	cancel context.CancelFunc
+	timer  *time.Timer
+}
+
+// New creates a server of the database.
+func New(db *chaintrackdb.DB) *Server {
+	return &Server{
+		db:                 db,
+		TransactionTimeout: DefaultTransactionTimeout,
+		MaxValueSize:       DefaultMaxValueSize,
+		mu:                 new(sync.Mutex),
+		txs:                map[string]*session{},
+	}
+}
+
+// Close rolls back all transactions in progress.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	sessions := s.txs
+	s.txs = map[string]*session{}
+	s.mu.Unlock()
+
+	for _, ses := range sessions {
+		ses.timer.Stop()
+		err := ses.rollback()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollback rolls back the transaction, waiting for the request using it to finish.
+func (ses *session) rollback() error {
+	ses.mu.Lock()
+	defer ses.mu.Unlock()
+
+	ses.cancel()
+
+	// cancelling leaves the transaction's blocks in use until it is rolled back
+	return ses.tx.Rollback()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "batch" && r.Method == http.MethodPost:
+		s.batch(w, r)
+	case len(parts) == 1 && parts[0] == "transactions" && r.Method == http.MethodPost:
+		s.begin(w, r)
+	case len(parts) == 3 && parts[0] == "transactions":
+		ses, err := s.session(parts[1])
+		if err != nil {
+			writeError(w, err, 0)
+			return
+		}
+
+		ses.mu.Lock()
+		defer ses.mu.Unlock()
+
+		switch {
+		case parts[2] == "ops" && r.Method == http.MethodPost:
+			s.ops(w, r, ses)
+		case parts[2] == "value" && r.Method == http.MethodGet:
+			s.getValue(w, r, ses)
+		case parts[2] == "value" && r.Method == http.MethodPut:
+			s.putValue(w, r, ses)
+		case parts[2] == "commit" && r.Method == http.MethodPost:
+			s.end(w, parts[1], ses, ses.tx.Commit)
+		case parts[2] == "rollback" && r.Method == http.MethodPost:
+			s.end(w, parts[1], ses, ses.tx.Rollback)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	req := BatchRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, errors.Wrap(errBadRequest, err.Error()), 0)
+		return
+	}
+
+	var results []Result
+	failed := 0
+
+	err = s.db.WriteTransaction(r.Context(), func(tx *chaintrackdb.WriteTransaction) error {
+		results, failed, err = runOps(tx, req.Ops)
+		return err
+	})
+	if err != nil {
+		writeError(w, err, failed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BatchResponse{Results: results})
+}
+
+func (s *Server) begin(w http.ResponseWriter, r *http.Request) {
+	// the transaction outlives the request
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tx, err := s.db.NewWriteTransaction(ctx)
+	if err != nil {
+		cancel()
+		writeError(w, err, 0)
+		return
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	id := strconv.FormatUint(s.nextID, 10)
+	s.txs[id] = &session{
+		mu:     new(sync.Mutex),
+		tx:     tx,
+		cancel: cancel,
+		timer:  time.AfterFunc(s.TransactionTimeout, func() { s.expire(id) }),
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, TransactionResponse{ID: id})
+}
+
+// session returns the transaction with the id and extends its timeout.
+func (s *Server) session(id string) (*session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ses := s.txs[id]
+	if ses == nil {
+		return nil, chaintrackdb.ErrTxClosed
+	}
+
+	ses.timer.Reset(s.TransactionTimeout)
+
+	return ses, nil
+}
+
+// expire rolls back a transaction that has not been used for TransactionTimeout.
+func (s *Server) expire(id string) {
+	s.mu.Lock()
+	ses := s.txs[id]
+	delete(s.txs, id)
+	s.mu.Unlock()
+
+	if ses == nil {
+		return
+	}
+
+	// there is nobody to report the error to, the transaction is gone either way
+	ses.rollback()
+}
+
+func (s *Server) ops(w http.ResponseWriter, r *http.Request, ses *session) {
+	req := BatchRequest{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeError(w, errors.Wrap(errBadRequest, err.Error()), 0)
+		return
+	}
+
+	results, failed, err := runOps(ses.tx, req.Ops)
+	if err != nil {
+		writeError(w, err, failed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BatchResponse{Results: results})
+}
+
+func (s *Server) getValue(w http.ResponseWriter, r *http.Request, ses *session) {
+	vr, err := ses.tx.GetReader(r.URL.Query().Get("path"))
+	if err != nil {
+		writeError(w, err, 0)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	// the status is already sent, a failing copy can only cut the response short
+	io.Copy(w, vr)
+}
+
+func (s *Server) putValue(w http.ResponseWriter, r *http.Request, ses *session) {
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, s.MaxValueSize)}
+
+	var err error
+	q := r.URL.Query()
+	if q.Get("all") == "true" {
+		err = ses.tx.PutAllFrom(q.Get("path"), body)
+	} else {
+		err = ses.tx.PutFrom(q.Get("path"), body)
+	}
+	// MaxBytesReader fails after the limit has been read
+	if err != nil && body.n >= s.MaxValueSize {
+		err = errors.Wrapf(errValueTooLarge, "value is larger than %d bytes", s.MaxValueSize)
+	}
+	if err != nil {
+		writeError(w, err, 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// end commits or rolls back the transaction and forgets it.
+func (s *Server) end(w http.ResponseWriter, id string, ses *session, f func() error) {
+	s.mu.Lock()
+	ses.timer.Stop()
+	delete(s.txs, id)
+	s.mu.Unlock()
+
+	err := f()
+	ses.cancel()
+	if err != nil {
+		writeError(w, err, 0)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runOps runs ops in the transaction until one of them fails.
+// Returns the index of the failed operation.
+func runOps(tx *chaintrackdb.WriteTransaction, ops []Op) ([]Result, int, error) {
+	results := make([]Result, len(ops))
+
+	for i, op := range ops {
+		var err error
+		res := &results[i]
+
+		switch op.Op {
+		case OpGet:
+			res.Value, err = tx.Get(op.Path)
+		case OpPut:
+			err = tx.Put(op.Path, op.Value)
+		case OpPutAll:
+			err = tx.PutAll(op.Path, op.Value)
+		case OpCreateMap:
+			err = tx.CreateMap(op.Path)
+		case OpCreateMapAll:
+			err = tx.CreateMapAll(op.Path)
+		case OpDelete:
+			err = tx.Delete(op.Path)
+		case OpExists:
+			res.Exists, err = tx.Exists(op.Path)
+		case OpCount:
+			res.Count, err = tx.Count(op.Path)
+		case OpKeys:
+			res.Keys = []string{}
+			err = tx.ForEachKey(op.Path, func(key string) error {
+				res.Keys = append(res.Keys, key)
+				return nil
+			})
+		default:
+			err = errors.Wrapf(errBadRequest, "unknown operation %q", op.Op)
+		}
+
+		if err != nil {
+			return nil, i, err
+		}
+	}
+
+	return results, 0, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error, op int) {
+	status, res := errorResponse(err, op)
+	writeJSON(w, status, res)
+}
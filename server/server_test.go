@@ -0,0 +1,144 @@
+package server_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	db, err := chaintrackdb.Open(td)
+	require.NoError(t, err)
+	defer db.Close()
+
+	srv := server.New(db)
+	defer srv.Close()
+
+	post := func(t *testing.T, path, body string) (int, server.ErrorResponse) {
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		er := server.ErrorResponse{}
+		if rec.Code >= 300 {
+			err := json.NewDecoder(rec.Body).Decode(&er)
+			require.NoError(t, err)
+		}
+		return rec.Code, er
+	}
+
+	t.Run("when the batch contains an unknown operation", func(t *testing.T) {
+		status, er := post(t, "/batch", `{"ops":[{"op":"put","path":"a","value":"YQ=="},{"op":"nope"}]}`)
+		t.Run("then I should get a bad request error for the operation", func(t *testing.T) {
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, server.CodeBadRequest, er.Code)
+			require.Equal(t, 1, er.Op)
+		})
+	})
+
+	t.Run("when the body is not JSON", func(t *testing.T) {
+		status, er := post(t, "/batch", `nope`)
+		t.Run("then I should get a bad request error", func(t *testing.T) {
+			require.Equal(t, http.StatusBadRequest, status)
+			require.Equal(t, server.CodeBadRequest, er.Code)
+		})
+	})
+
+	t.Run("when using an unknown transaction", func(t *testing.T) {
+		status, er := post(t, "/transactions/42/commit", "")
+		t.Run("then I should get a tx closed error", func(t *testing.T) {
+			require.Equal(t, http.StatusGone, status)
+			require.Equal(t, server.CodeTxClosed, er.Code)
+			require.Equal(t, chaintrackdb.ErrTxClosed, server.ErrorForCode(er.Code))
+		})
+	})
+
+	t.Run("when a value larger than MaxValueSize is put", func(t *testing.T) {
+		srv.MaxValueSize = 10
+		defer func() {
+			srv.MaxValueSize = server.DefaultMaxValueSize
+		}()
+
+		rec := httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/transactions", nil))
+		tr := server.TransactionResponse{}
+		err := json.NewDecoder(rec.Body).Decode(&tr)
+		require.NoError(t, err)
+
+		rec = httptest.NewRecorder()
+		srv.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/transactions/"+tr.ID+"/value?path=v", strings.NewReader("0123456789a")))
+
+		t.Run("then I should get a too large error", func(t *testing.T) {
+			require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+			er := server.ErrorResponse{}
+			err := json.NewDecoder(rec.Body).Decode(&er)
+			require.NoError(t, err)
+			require.Equal(t, server.CodeTooLarge, er.Code)
+		})
+
+		status, _ := post(t, "/transactions/"+tr.ID+"/rollback", "")
+		require.Equal(t, http.StatusNoContent, status)
+	})
+
+	t.Run("when a transaction expires", func(t *testing.T) {
+		srv.TransactionTimeout = 10 * time.Millisecond
+		defer func() {
+			srv.TransactionTimeout = server.DefaultTransactionTimeout
+		}()
+
+		before := openTxSegments(t)
+
+		status, _ := post(t, "/transactions", "")
+		require.Equal(t, http.StatusCreated, status)
+		require.Equal(t, before+1, openTxSegments(t))
+
+		t.Run("then its tx segment should be released", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				return openTxSegments(t) == before
+			}, time.Second, 5*time.Millisecond)
+		})
+	})
+
+	t.Run("when the server is closed with a transaction in progress", func(t *testing.T) {
+		before := openTxSegments(t)
+
+		status, _ := post(t, "/transactions", "")
+		require.Equal(t, http.StatusCreated, status)
+
+		err = srv.Close()
+		require.NoError(t, err)
+
+		t.Run("then its tx segment should be released", func(t *testing.T) {
+			require.Equal(t, before, openTxSegments(t))
+		})
+	})
+}
+
+// openTxSegments returns the number of tx segment files opened by the process.
+func openTxSegments(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("open files can't be listed")
+	}
+
+	cnt := 0
+	for _, fd := range fds {
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+		if err == nil && strings.Contains(filepath.Base(target), "tx-") {
+			cnt++
+		}
+	}
+	return cnt
+}
@@ -0,0 +1,102 @@
+package chaintrackdb
+
+import (
+	"fmt"
+
+	"github.com/draganm/chaintrackdb/btree"
+	"github.com/draganm/chaintrackdb/data"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// Kind is the kind of element stored at a path.
+type Kind byte
+
+const (
+	KindValue Kind = iota
+	KindMap
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindValue:
+		return "Value"
+	case KindMap:
+		return "Map"
+	default:
+		return fmt.Sprintf("Undefined kind %d", k)
+	}
+}
+
+// Stat describes an element stored at a path.
+type Stat struct {
+	Kind Kind
+	// Size is the number of data bytes for values and the number of keys for maps.
+	Size uint64
+	// ValueType is the type of the value, it's not set for maps.
+	ValueType ValueType
+	// Address is the address of the element's root block.
+	Address store.Address
+}
+
+// Stat returns information about the element stored at path.
+func (w *WriteTransaction) Stat(path string) (Stat, error) {
+	addr, err := w.pathElementAddress(path)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	return stat(w.swt, w.mergeOperators, addr)
+}
+
+func stat(r store.Reader, ops mergeOperators, addr store.Address) (Stat, error) {
+	br, err := r.GetBlock(addr)
+	if err != nil {
+		return Stat{}, errors.Wrap(err, "while reading block")
+	}
+
+	if br.Type() == store.TypeBTreeNode {
+		cnt, err := btree.Count(r, addr)
+		if err != nil {
+			return Stat{}, errors.Wrap(err, "while counting keys")
+		}
+
+		return Stat{
+			Kind:    KindMap,
+			Size:    cnt,
+			Address: addr,
+		}, nil
+	}
+
+	if br.Type() == store.TypeMergeNode {
+		vt, d, err := foldMergeNode(r, ops, addr)
+		if err != nil {
+			return Stat{}, err
+		}
+
+		return Stat{
+			Kind:      KindValue,
+			Size:      uint64(len(d)),
+			ValueType: vt,
+			Address:   addr,
+		}, nil
+	}
+
+	vt, da, err := data.ValueType(r, addr)
+	if err != nil {
+		return Stat{}, errors.Wrap(err, "while reading value type")
+	}
+
+	size, err := data.Size(r, da)
+	if err != nil {
+		return Stat{}, errors.Wrap(err, "while reading value size")
+	}
+
+	return Stat{
+		Kind:      KindValue,
+		Size:      size,
+		ValueType: ValueType(vt),
+		Address:   addr,
+	}, nil
+
+}
@@ -0,0 +1,142 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStat(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.CreateMap("map")
+		require.NoError(t, err)
+
+		err = tx.Put("map/a", []byte{1, 2, 3})
+		require.NoError(t, err)
+
+		err = tx.Put("map/b", []byte{4})
+		require.NoError(t, err)
+
+		return tx.PutString("value", "hello")
+	})
+	require.NoError(t, err)
+
+	t.Run("when I stat a map", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			st, err := tx.Stat("map")
+			require.NoError(t, err)
+
+			t.Run("then kind should be map and size number of keys", func(t *testing.T) {
+				require.Equal(t, chaintrackdb.KindMap, st.Kind)
+				require.Equal(t, uint64(2), st.Size)
+			})
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("when I stat a value", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			st, err := tx.Stat("value")
+			require.NoError(t, err)
+
+			t.Run("then kind should be value and size number of bytes", func(t *testing.T) {
+				require.Equal(t, chaintrackdb.KindValue, st.Kind)
+				require.Equal(t, uint64(5), st.Size)
+				require.Equal(t, chaintrackdb.ValueTypeString, st.ValueType)
+			})
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("when I stat a path that does not exist", func(t *testing.T) {
+		err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			_, err := tx.Stat("map/c")
+			return err
+		})
+		require.Equal(t, chaintrackdb.ErrNotFound, err)
+	})
+
+}
+
+func TestMapAndValueErrors(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+		err := tx.CreateMap("map")
+		require.NoError(t, err)
+		return tx.Put("value", []byte{1})
+	})
+	require.NoError(t, err)
+
+	cases := []struct {
+		title    string
+		op       func(tx *chaintrackdb.WriteTransaction) error
+		expected error
+	}{
+		{
+			title: "get of a map",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.Get("map")
+				return err
+			},
+			expected: chaintrackdb.ErrIsMap,
+		},
+		{
+			title: "get below a value",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.Get("value/a")
+				return err
+			},
+			expected: chaintrackdb.ErrNotMap,
+		},
+		{
+			title: "put below a value",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("value/a", []byte{1})
+			},
+			expected: chaintrackdb.ErrNotMap,
+		},
+		{
+			title: "put two levels below a value",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.Put("value/a/b", []byte{1})
+			},
+			expected: chaintrackdb.ErrNotMap,
+		},
+		{
+			title: "create map below a value",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				return tx.CreateMap("value/a")
+			},
+			expected: chaintrackdb.ErrNotMap,
+		},
+		{
+			title: "count of a value",
+			op: func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.Count("value")
+				return err
+			},
+			expected: chaintrackdb.ErrNotMap,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.title, func(t *testing.T) {
+			err := db.WriteTransaction(ctx, tc.op)
+			require.Equal(t, tc.expected, errors.Cause(err))
+		})
+	}
+}
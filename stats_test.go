@@ -0,0 +1,109 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// markWalkSize returns the total size of all distinct blocks reachable from root.
+func markWalkSize(t *testing.T, r store.Reader, root store.Address) uint64 {
+	seen := map[store.Address]bool{}
+	var total uint64
+	var walk func(a store.Address)
+	walk = func(a store.Address) {
+		if a == store.NilAddress || seen[a] {
+			return
+		}
+		seen[a] = true
+		br, err := r.GetBlock(a)
+		require.NoError(t, err)
+		total += uint64(len(br))
+		for i := 0; i < br.NumberOfChildren(); i++ {
+			walk(br.GetChildAddress(i))
+		}
+	}
+	walk(root)
+	return total
+}
+
+func TestLiveBytes(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	rnd := rand.New(rand.NewSource(1))
+
+	mapPath := func() string {
+		return fmt.Sprintf("m%d", rnd.Intn(5))
+	}
+
+	for round := 0; round < 5; round++ {
+		db, err := chaintrackdb.Open(td)
+		require.NoError(t, err)
+
+		for i := 0; i < 30; i++ {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				p := mapPath()
+				switch rnd.Intn(4) {
+				case 0:
+					return tx.PutAll(fmt.Sprintf("%s/k%d", p, rnd.Intn(20)), []byte(p))
+				case 1:
+					ex, err := tx.Exists(p)
+					require.NoError(t, err)
+					if !ex {
+						return nil
+					}
+					err = tx.Copy(p, fmt.Sprintf("%s/c%d", mapPath(), rnd.Intn(3)))
+					if errors.Cause(err) == chaintrackdb.ErrNotFound {
+						return nil
+					}
+					return err
+				case 2:
+					err := tx.Delete(fmt.Sprintf("%s/k%d", p, rnd.Intn(20)))
+					if errors.Cause(err) == chaintrackdb.ErrNotFound {
+						return nil
+					}
+					return err
+				default:
+					src := mapPath()
+					dst := mapPath()
+					ex, err := tx.Exists(src)
+					require.NoError(t, err)
+					if !ex || src == dst {
+						return nil
+					}
+					_, err = tx.Stat(dst)
+					if err == chaintrackdb.ErrNotFound {
+						return tx.Move(src, dst)
+					}
+					return err
+				}
+			})
+			require.NoError(t, err)
+		}
+
+		stats, err := db.Stats()
+		require.NoError(t, err)
+
+		err = db.Close()
+		require.NoError(t, err)
+
+		t.Run(fmt.Sprintf("then live bytes should match a mark walk after round %d", round), func(t *testing.T) {
+			s, err := store.Open(td)
+			require.NoError(t, err)
+			defer s.Close()
+
+			require.Equal(t, stats.Root, s.LastCommitAddress())
+			require.Equal(t, markWalkSize(t, s, stats.Root), stats.LiveBytes)
+			require.True(t, stats.LiveBytes <= stats.OccupiedBytes)
+		})
+	}
+}
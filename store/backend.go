@@ -0,0 +1,32 @@
+package store
+
+// Backend holds the files of a store: its segments, the segments of
+// transactions in progress and the commit address.
+// Files are accessed as memory, so blocks can be read and written in place.
+type Backend interface {
+	// Names returns names of all files.
+	Names() ([]string, error)
+	// Create creates an empty file that can grow up to maxSize bytes,
+	// replacing an existing file with the same name.
+	Create(name string, maxSize uint64) (File, error)
+	// Open opens an existing file that can grow up to maxSize bytes.
+	Open(name string, maxSize uint64, readOnly bool) (File, error)
+	// Remove removes the file. A removed file stays usable until it is closed.
+	Remove(name string) error
+	// Close releases all resources of the backend. It is called when the store is closed.
+	Close() error
+}
+
+// File is a file of a backend.
+type File interface {
+	Name() string
+	// Bytes returns the memory of the file up to its maximal size.
+	// Only bytes up to Size can be used, the memory must not move when the file grows.
+	Bytes() []byte
+	Size() uint64
+	// Truncate changes the size of the file.
+	Truncate(size uint64) error
+	// Flush makes sure the changes of the file are durable.
+	Flush() error
+	Close() error
+}
@@ -0,0 +1,111 @@
+package store_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	b := store.NewMemoryBackend(store.DefaultMaxMemoryFileSize)
+
+	t.Run("when I open a store in an empty memory backend", func(t *testing.T) {
+		st, err := store.OpenBackend(b)
+		require.NoError(t, err)
+		defer st.Close()
+
+		names, err := b.Names()
+		require.NoError(t, err)
+
+		t.Run("then it should create the first segment, the commit address and the store id", func(t *testing.T) {
+			require.Equal(t, []string{"commitAddress", "segment-0000000000000001", "storeID"}, names)
+		})
+
+		t.Run("when I commit a transaction", func(t *testing.T) {
+			tx, _, err := st.NewWriteTransaction(context.Background())
+			require.NoError(t, err)
+
+			bw, err := tx.AppendBlock(store.TypeDataLeaf, 0, 3)
+			require.NoError(t, err)
+			copy(bw.Data, "abc")
+
+			root, err := tx.Commit(bw.Address)
+			require.NoError(t, err)
+
+			t.Run("then the block should be readable", func(t *testing.T) {
+				br, err := st.GetBlock(root)
+				require.NoError(t, err)
+				require.Equal(t, []byte("abc"), br.GetData())
+			})
+
+			t.Run("then the tx segment should be removed", func(t *testing.T) {
+				names, err := b.Names()
+				require.NoError(t, err)
+				for _, n := range names {
+					require.False(t, strings.HasPrefix(n, "tx-"), n)
+				}
+			})
+		})
+	})
+
+	t.Run("when a file is removed while it is open", func(t *testing.T) {
+		f, err := b.Create("f", 1024)
+		require.NoError(t, err)
+
+		err = f.Truncate(10)
+		require.NoError(t, err)
+		f.Bytes()[9] = 42
+
+		err = b.Remove("f")
+		require.NoError(t, err)
+
+		t.Run("then it should stay usable until it is closed", func(t *testing.T) {
+			require.Equal(t, byte(42), f.Bytes()[9])
+			require.NoError(t, f.Close())
+		})
+
+		t.Run("then opening it should fail", func(t *testing.T) {
+			_, err = b.Open("f", 1024, false)
+			require.Error(t, err)
+		})
+	})
+
+	t.Run("when a file is shrunk and grown", func(t *testing.T) {
+		f, err := b.Create("g", 1024)
+		require.NoError(t, err)
+		defer f.Close()
+
+		err = f.Truncate(10)
+		require.NoError(t, err)
+		f.Bytes()[9] = 42
+
+		err = f.Truncate(5)
+		require.NoError(t, err)
+		err = f.Truncate(10)
+		require.NoError(t, err)
+
+		t.Run("then the grown part should be zeroed", func(t *testing.T) {
+			require.Equal(t, byte(0), f.Bytes()[9])
+		})
+	})
+
+	t.Run("when a file is created larger than the maximal file size", func(t *testing.T) {
+		b := store.NewMemoryBackend(1024)
+		defer b.Close()
+
+		f, err := b.Create("h", 4096)
+		require.NoError(t, err)
+		defer f.Close()
+
+		t.Run("then it should grow up to the maximal file size", func(t *testing.T) {
+			require.NoError(t, f.Truncate(1024))
+		})
+
+		t.Run("then growing it beyond the maximal file size should fail", func(t *testing.T) {
+			require.Error(t, f.Truncate(1025))
+		})
+	})
+}
@@ -0,0 +1,414 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// backup stream layout
+//
+// magic - 8 bytes
+// root of the previous backup for incremental backups, otherwise zero - 8 bytes
+// blocks, children before their parents:
+//   original address - 8 bytes
+//   block length - 2 bytes
+//   block
+// end marker, a zero address - 8 bytes
+// original address of the root - 8 bytes
+// crc32 of everything before - 4 bytes
+
+var backupMagic = []byte("CTDBBAK1")
+
+// ErrInvalidBackup is returned when restoring from a stream that is not a valid backup.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Backup writes all blocks reachable from the last commited root to w.
+// Blocks stay readable during the backup even if transactions are commited in the meantime.
+func (s *Store) Backup(ctx context.Context, w io.Writer) error {
+	_, err := s.IncrementalBackup(ctx, NilAddress, w)
+	return err
+}
+
+// IncrementalBackup writes the blocks reachable from the last commited root
+// that have been appended after since, the root of the previous backup.
+// Blocks are only appended, so every other reachable block has been a part of the previous backup.
+// Returns the root of the backup. If since is NilAddress, all reachable blocks are written.
+func (s *Store) IncrementalBackup(ctx context.Context, since Address, w io.Writer) (Address, error) {
+	id, root := s.pinRoot()
+	defer s.txFinished(id)
+
+	if since > root {
+		return NilAddress, errors.Errorf("previous backup root %d is higher than the last commited root %d", since, root)
+	}
+
+	return root, WriteBackup(ctx, s, since, root, w)
+}
+
+// WriteBackup writes all blocks reachable from root with an address higher than since to w,
+// so they can be restored with Restore or RestoreChain.
+// Every block is written once, even if it is referenced from more than one parent.
+// Addresses of written shared blocks and their descendants are kept in memory until the backup is written.
+func WriteBackup(ctx context.Context, r Reader, since, root Address, w io.Writer) error {
+	bw := &backupWriter{
+		ctx:     ctx,
+		r:       r,
+		since:   since,
+		crc:     crc32.NewIEEE(),
+		written: map[Address]bool{},
+	}
+	bw.w = bufio.NewWriter(io.MultiWriter(w, bw.crc))
+
+	err := bw.write(backupMagic)
+	if err != nil {
+		return err
+	}
+
+	err = bw.writeAddress(since)
+	if err != nil {
+		return err
+	}
+
+	err = bw.writeBlock(root, false)
+	if err != nil {
+		return err
+	}
+
+	err = bw.writeAddress(NilAddress)
+	if err != nil {
+		return err
+	}
+
+	err = bw.writeAddress(root)
+	if err != nil {
+		return err
+	}
+
+	err = bw.w.Flush()
+	if err != nil {
+		return errors.Wrap(err, "while writing backup")
+	}
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, bw.crc.Sum32())
+	_, err = w.Write(sum)
+	if err != nil {
+		return errors.Wrap(err, "while writing backup checksum")
+	}
+
+	return nil
+}
+
+type backupWriter struct {
+	ctx   context.Context
+	r     Reader
+	since Address
+	w     *bufio.Writer
+	crc   hash.Hash32
+	// written contains addresses of written descendants of shared blocks.
+	written map[Address]bool
+}
+
+func (b *backupWriter) write(d []byte) error {
+	_, err := b.w.Write(d)
+	if err != nil {
+		return errors.Wrap(err, "while writing backup")
+	}
+	return nil
+}
+
+func (b *backupWriter) writeAddress(a Address) error {
+	d := make([]byte, 8)
+	binary.BigEndian.PutUint64(d, uint64(a))
+	return b.write(d)
+}
+
+func (b *backupWriter) writeBlock(a Address, inShared bool) error {
+	// children have lower addresses than their parents,
+	// so the whole subtree is in the previous backup
+	if a <= b.since {
+		return nil
+	}
+
+	err := b.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	br, err := b.r.GetBlock(a)
+	if err != nil {
+		return errors.Wrapf(err, "while getting block %d", a)
+	}
+
+	// only descendants of shared blocks can have more than one parent,
+	// so other blocks are reached once and don't have to be tracked
+	inShared = inShared || br.Shared()
+	if inShared {
+		if b.written[a] {
+			return nil
+		}
+		b.written[a] = true
+	}
+
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		err = b.writeBlock(br.GetChildAddress(i), inShared)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.writeAddress(a)
+	if err != nil {
+		return err
+	}
+
+	err = b.write(br[:2])
+	if err != nil {
+		return err
+	}
+
+	err = b.write(br)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Restore creates a new store in dir from a full backup written by Backup or WriteBackup.
+// dir must not contain a store. Returns the address of the restored root.
+func Restore(r io.Reader, dir string) (Address, error) {
+	return RestoreChain(dir, r)
+}
+
+// RestoreChain creates a new store in dir from a full backup followed by incremental backups,
+// each one taken since the previous one. The restored root is the root of the last backup.
+// The original and the restored address of every block of the chain are kept in memory
+// until the whole chain is restored, roughly 50 bytes per block.
+func RestoreChain(dir string, backups ...io.Reader) (Address, error) {
+	if len(backups) == 0 {
+		return NilAddress, errors.New("no backups to restore")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "segment-*"))
+	if err != nil {
+		return NilAddress, err
+	}
+	if len(files) > 0 {
+		return NilAddress, errors.Errorf("%s already contains a store", dir)
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return NilAddress, errors.Wrapf(err, "while creating %s", dir)
+	}
+
+	b := NewFileBackend(dir)
+
+	seg, err := createSegment(b, segmentName(1), MaxSegmentSize, 1)
+	if err != nil {
+		return NilAddress, err
+	}
+
+	rs := &restorer{
+		seg:      seg,
+		restored: map[Address]Address{},
+	}
+
+	for i, r := range backups {
+		err = rs.restore(r)
+		if err != nil {
+			seg.closeAndRemove()
+			return NilAddress, errors.Wrapf(err, "while restoring backup %d", i)
+		}
+	}
+
+	err = seg.close()
+	if err != nil {
+		return NilAddress, err
+	}
+
+	ca, err := openCommitAddress(b)
+	if err != nil {
+		return NilAddress, err
+	}
+
+	root := rs.restored[rs.lastRoot]
+	ca.setAddress(root)
+
+	err = ca.close()
+	if err != nil {
+		return NilAddress, err
+	}
+
+	return root, nil
+}
+
+// segmentReader reads blocks of a single segment.
+type segmentReader struct {
+	*segment
+}
+
+func (s segmentReader) GetBlock(a Address) (BlockReader, error) {
+	return s.getBlock(a)
+}
+
+type restorer struct {
+	seg *segment
+	// restored maps addresses of blocks in backups to their restored addresses.
+	restored map[Address]Address
+	// lastRoot is the original root address of the last restored backup.
+	lastRoot Address
+}
+
+func (rs *restorer) restore(r io.Reader) error {
+	crc := crc32.NewIEEE()
+	br := bufio.NewReader(r)
+	tr := io.TeeReader(br, crc)
+
+	readFull := func(d []byte) error {
+		_, err := io.ReadFull(tr, d)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.Wrap(ErrInvalidBackup, "unexpected end of backup")
+		}
+		return err
+	}
+
+	buf := make([]byte, 0xffff)
+
+	err := readFull(buf[:len(backupMagic)])
+	if err != nil {
+		return err
+	}
+
+	if string(buf[:len(backupMagic)]) != string(backupMagic) {
+		return errors.Wrap(ErrInvalidBackup, "wrong magic")
+	}
+
+	err = readFull(buf[:8])
+	if err != nil {
+		return err
+	}
+
+	since := Address(binary.BigEndian.Uint64(buf))
+	if since != rs.lastRoot {
+		return errors.Wrapf(ErrInvalidBackup, "backup was taken since %d, not since the previous backup %d", since, rs.lastRoot)
+	}
+
+	for {
+		err = readFull(buf[:8])
+		if err != nil {
+			return err
+		}
+
+		original := Address(binary.BigEndian.Uint64(buf))
+		if original == NilAddress {
+			break
+		}
+
+		err = readFull(buf[:2])
+		if err != nil {
+			return err
+		}
+
+		blockLength := int(binary.BigEndian.Uint16(buf))
+
+		err = readFull(buf[:blockLength])
+		if err != nil {
+			return err
+		}
+
+		rs.restored[original], err = restoreBlock(rs.seg, buf[:blockLength], rs.restored)
+		if err != nil {
+			return errors.Wrapf(err, "while restoring block %d", original)
+		}
+	}
+
+	err = readFull(buf[:8])
+	if err != nil {
+		return err
+	}
+
+	root := Address(binary.BigEndian.Uint64(buf))
+	_, found := rs.restored[root]
+	if !found {
+		return errors.Wrap(ErrInvalidBackup, "root block is missing")
+	}
+
+	sum := crc.Sum32()
+
+	_, err = io.ReadFull(br, buf[:4])
+	if err != nil {
+		return errors.Wrap(ErrInvalidBackup, "checksum is missing")
+	}
+
+	if binary.BigEndian.Uint32(buf) != sum {
+		return errors.Wrap(ErrInvalidBackup, "checksum mismatch")
+	}
+
+	rs.lastRoot = root
+
+	return nil
+}
+
+// restoreBlock appends the block to the segment, replacing the addresses of children with their restored addresses.
+func restoreBlock(seg *segment, block []byte, restored map[Address]Address) (Address, error) {
+	br, err := NewBlockReader(block)
+	if err != nil {
+		return NilAddress, errors.Wrap(ErrInvalidBackup, err.Error())
+	}
+
+	if int(br.BlockSize()) != len(block) {
+		return NilAddress, errors.Wrap(ErrInvalidBackup, "wrong block size")
+	}
+
+	addr, nbd, err := seg.appendBlock(uint64(len(br)))
+	if err != nil {
+		return NilAddress, errors.Wrap(err, "while appending block")
+	}
+
+	copy(nbd, br)
+
+	// keep only the shared flag, the rest is set with the children
+	binary.BigEndian.PutUint64(nbd[2:], uint64(len(nbd))|(binary.BigEndian.Uint64(br[2:])&sharedFlag))
+	binary.BigEndian.PutUint64(nbd[2+8:], uint64(addr))
+
+	numberOfChildren := br.NumberOfChildren()
+
+	for i := 0; i < numberOfChildren; i++ {
+		binary.BigEndian.PutUint64(nbd[2+8+8+1+1+8*i:], 0)
+	}
+
+	bw := BlockWriter{
+		st:          segmentReader{seg},
+		Address:     addr,
+		BlockReader: BlockReader(nbd),
+		Data:        BlockReader(nbd).GetData(),
+	}
+
+	for i := 0; i < numberOfChildren; i++ {
+		ca := br.GetChildAddress(i)
+		if ca == NilAddress {
+			continue
+		}
+
+		na, found := restored[ca]
+		if !found {
+			return NilAddress, errors.Wrapf(ErrInvalidBackup, "child %d at %d has not been restored", i, ca)
+		}
+
+		err = bw.SetChild(i, na)
+		if err != nil {
+			return NilAddress, errors.Wrap(err, "while setting child")
+		}
+	}
+
+	return addr, nil
+}
@@ -8,7 +8,7 @@ import (
 
 // layout
 // block length: 2 bytes
-// data size: 8 bytes
+// data size: 8 bytes, the two highest bits are flags
 // lowest descendent address: 8 bytes
 // type: byte
 // number_of_children: 1 byte
@@ -17,6 +17,15 @@ import (
 
 type BlockReader []byte
 
+const (
+	// sharedFlag marks blocks whose subtree can be referenced from more than one parent.
+	sharedFlag = uint64(1) << 63
+	// containsSharedFlag marks blocks having a shared block among their descendants.
+	containsSharedFlag = uint64(1) << 62
+
+	usedSizeFlags = sharedFlag | containsSharedFlag
+)
+
 func NewBlockReader(data []byte) (BlockReader, error) {
 	if len(data) < 2+8+8+1+1 {
 		return nil, errors.New("block data is too short")
@@ -65,8 +74,20 @@ func (s BlockReader) GetData() []byte {
 	return s[2+8+8+1+1+8*nc:]
 }
 
+// GetUsedDataSize returns the size of the block and all its descendants.
+// Blocks shared by more than one parent are counted once for every parent.
 func (s BlockReader) GetUsedDataSize() uint64 {
-	return binary.BigEndian.Uint64(s[2:])
+	return binary.BigEndian.Uint64(s[2:]) &^ usedSizeFlags
+}
+
+// Shared returns true if the block or blocks of its subtree can be referenced from more than one parent.
+func (s BlockReader) Shared() bool {
+	return binary.BigEndian.Uint64(s[2:])&sharedFlag != 0
+}
+
+// ContainsShared returns true if any of the descendants of the block is shared.
+func (s BlockReader) ContainsShared() bool {
+	return binary.BigEndian.Uint64(s[2:])&containsSharedFlag != 0
 }
 
 func (s BlockReader) GetLowestDescendentAddress() Address {
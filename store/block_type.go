@@ -10,14 +10,18 @@ const (
 	TypeDataLeaf
 	TypeDataNode
 	TypeBTreeNode
+	TypeTypedValue
+	TypeMergeNode
 )
 
 var BlockTypeNameMap = map[BlockType]string{
-	TypeUndefined: "Undefined",
-	TypeCommit:    "Commit",
-	TypeDataLeaf:  "DataLeaf",
-	TypeDataNode:  "DataNode",
-	TypeBTreeNode: "BTreeNode",
+	TypeUndefined:  "Undefined",
+	TypeCommit:     "Commit",
+	TypeDataLeaf:   "DataLeaf",
+	TypeDataNode:   "DataNode",
+	TypeBTreeNode:  "BTreeNode",
+	TypeTypedValue: "TypedValue",
+	TypeMergeNode:  "MergeNode",
 }
 
 func (s BlockType) String() string {
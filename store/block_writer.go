@@ -47,6 +47,7 @@ func (w BlockWriter) SetChild(i int, addr Address) error {
 	w.addUsedData(newChildReader.GetUsedDataSize())
 
 	lowest := w.Address
+	containsShared := false
 
 	for i := 0; i < w.NumberOfChildren(); i++ {
 		childAddress := w.GetChildAddress(i)
@@ -62,24 +63,48 @@ func (w BlockWriter) SetChild(i int, addr Address) error {
 		if lowest > lcd {
 			lowest = lcd
 		}
+
+		if newChildReader.Shared() || newChildReader.ContainsShared() {
+			containsShared = true
+		}
 	}
 
 	binary.BigEndian.PutUint64(w.BlockReader[2+8:], uint64(lowest))
+	w.setFlag(containsSharedFlag, containsShared)
 
 	return nil
 
 }
 
+// SetShared marks the block as one whose subtree can be referenced from more than one parent.
+func (w BlockWriter) SetShared() {
+	w.setFlag(sharedFlag, true)
+}
+
+func (w BlockWriter) setFlag(flag uint64, set bool) {
+	v := binary.BigEndian.Uint64(w.BlockReader[2:])
+	if set {
+		v |= flag
+	} else {
+		v &^= flag
+	}
+	binary.BigEndian.PutUint64(w.BlockReader[2:], v)
+}
+
+func (w BlockWriter) flags() uint64 {
+	return binary.BigEndian.Uint64(w.BlockReader[2:]) & usedSizeFlags
+}
+
 func (w BlockWriter) subtractUsedData(bytes uint64) error {
 	used := w.GetUsedDataSize()
 	if bytes > used {
 		return errors.New("subtracting more data than used")
 	}
-	binary.BigEndian.PutUint64(w.BlockReader[2:], used-bytes)
+	binary.BigEndian.PutUint64(w.BlockReader[2:], (used-bytes)|w.flags())
 	return nil
 }
 
 func (w BlockWriter) addUsedData(bytes uint64) {
 	used := w.GetUsedDataSize()
-	binary.BigEndian.PutUint64(w.BlockReader[2:], used+bytes)
+	binary.BigEndian.PutUint64(w.BlockReader[2:], (used+bytes)|w.flags())
 }
@@ -0,0 +1,268 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Problem is an inconsistency found by Check.
+type Problem struct {
+	Address     Address
+	Description string
+	// Repaired is true if the problem has been fixed.
+	Repaired bool
+}
+
+func (p Problem) String() string {
+	if p.Repaired {
+		return fmt.Sprintf("block %d: %s (repaired)", p.Address, p.Description)
+	}
+	return fmt.Sprintf("block %d: %s", p.Address, p.Description)
+}
+
+// Report is the result of Check.
+type Report struct {
+	Root Address
+	// Blocks is the number of blocks reachable from the root.
+	Blocks int
+	// Bytes is the total size of the blocks reachable from the root.
+	Bytes    uint64
+	Problems []Problem
+}
+
+// OK returns true if there are no problems that have not been repaired.
+func (r Report) OK() bool {
+	for _, p := range r.Problems {
+		if !p.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// BlockChecker validates the content of a block.
+// Problems of children should not be reported, every reachable block is checked on its own.
+type BlockChecker func(r Reader, a Address, br BlockReader) error
+
+// CheckOptions are options of CheckWithOptions.
+type CheckOptions struct {
+	// Repair fixes the used size, the lowest descendant address and the flags
+	// of blocks in place. Other problems can't be repaired.
+	Repair bool
+	// Checkers validate the contents of blocks of their type.
+	Checkers map[BlockType]BlockChecker
+}
+
+// Check checks every block reachable from the last commited root of the store in dir.
+// The store must not be opened by anyone else during the check.
+func Check(dir string) (Report, error) {
+	return CheckWithOptions(dir, CheckOptions{})
+}
+
+// CheckWithOptions is Check with content checkers and optional repair.
+func CheckWithOptions(dir string, opts CheckOptions) (Report, error) {
+	open := OpenReadOnly
+	if opts.Repair {
+		open = Open
+	}
+
+	s, err := open(dir)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report, err := s.check(opts)
+	cerr := s.Close()
+	if err != nil {
+		return Report{}, err
+	}
+
+	return report, cerr
+}
+
+type checker struct {
+	s       *Store
+	opts    CheckOptions
+	report  *Report
+	checked map[Address]bool
+}
+
+func (s *Store) check(opts CheckOptions) (Report, error) {
+	report := &Report{Root: s.LastCommitAddress()}
+
+	segments := s.Segments()
+	for i := 1; i < len(segments); i++ {
+		if segments[i-1].EndAddress > segments[i].StartAddress {
+			report.Problems = append(report.Problems, Problem{
+				Description: fmt.Sprintf("segment %s overlaps with segment %s", segments[i-1].Name, segments[i].Name),
+			})
+		}
+	}
+
+	c := &checker{
+		s:       s,
+		opts:    opts,
+		report:  report,
+		checked: map[Address]bool{},
+	}
+
+	_, err := s.GetBlock(report.Root)
+	if err != nil {
+		report.Problems = append(report.Problems, Problem{
+			Address:     report.Root,
+			Description: fmt.Sprintf("commited root can't be read: %s", err),
+		})
+		return *report, nil
+	}
+
+	err = c.check(report.Root)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return *report, nil
+}
+
+func (c *checker) problem(a Address, format string, args ...interface{}) {
+	c.report.Problems = append(c.report.Problems, Problem{
+		Address:     a,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// check checks the children of the block before the block itself,
+// so that repaired children are used to check their parents.
+func (c *checker) check(a Address) error {
+	if c.checked[a] {
+		return nil
+	}
+	c.checked[a] = true
+
+	br, err := c.s.GetBlock(a)
+	if err != nil {
+		return errors.Wrapf(err, "while getting block %d", a)
+	}
+
+	c.report.Blocks++
+	c.report.Bytes += uint64(len(br))
+
+	if _, known := BlockTypeNameMap[br.Type()]; !known || br.Type() == TypeUndefined {
+		c.problem(a, "unknown block type %d", br.Type())
+	}
+
+	// header fields can only be verified if all children can be read
+	childrenOK := true
+
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		ca := br.GetChildAddress(i)
+		if ca == NilAddress {
+			continue
+		}
+
+		// blocks are appended after their children
+		if ca >= a {
+			c.problem(a, "child %d at %d is not lower than the block address", i, ca)
+			childrenOK = false
+			continue
+		}
+
+		_, err = c.s.GetBlock(ca)
+		if err != nil {
+			c.problem(a, "child %d at %d can't be read: %s", i, ca, err)
+			childrenOK = false
+			continue
+		}
+
+		err = c.check(ca)
+		if err != nil {
+			return err
+		}
+	}
+
+	if childrenOK {
+		err = c.checkHeader(a, br)
+		if err != nil {
+			return err
+		}
+
+		checker := c.opts.Checkers[br.Type()]
+		if checker != nil {
+			err = checker(c.s, a, br)
+			if err != nil {
+				c.problem(a, "%s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkHeader checks the fields of the block derived from its children.
+func (c *checker) checkHeader(a Address, br BlockReader) error {
+	used := uint64(len(br))
+	lowest := a
+	containsShared := false
+
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		ca := br.GetChildAddress(i)
+		if ca == NilAddress {
+			continue
+		}
+
+		cr, err := c.s.GetBlock(ca)
+		if err != nil {
+			return errors.Wrapf(err, "while getting block %d", ca)
+		}
+
+		used += cr.GetUsedDataSize()
+		if cr.GetLowestDescendentAddress() < lowest {
+			lowest = cr.GetLowestDescendentAddress()
+		}
+		if cr.Shared() || cr.ContainsShared() {
+			containsShared = true
+		}
+	}
+
+	if br.BlockSize() != uint64(len(br)) {
+		c.problem(a, "block size %d doesn't match block length %d", br.BlockSize(), len(br))
+	}
+
+	changed := false
+
+	repair := func(format string, args ...interface{}) {
+		changed = true
+		c.report.Problems = append(c.report.Problems, Problem{
+			Address:     a,
+			Description: fmt.Sprintf(format, args...),
+			Repaired:    c.opts.Repair,
+		})
+	}
+
+	if br.GetUsedDataSize() != used {
+		repair("used data size is %d, should be %d", br.GetUsedDataSize(), used)
+	}
+
+	if br.GetLowestDescendentAddress() != lowest {
+		repair("lowest descendant address is %d, should be %d", br.GetLowestDescendentAddress(), lowest)
+	}
+
+	if br.ContainsShared() != containsShared {
+		repair("contains shared flag is %t, should be %t", br.ContainsShared(), containsShared)
+	}
+
+	if !c.opts.Repair || !changed {
+		return nil
+	}
+
+	flags := binary.BigEndian.Uint64(br[2:]) & sharedFlag
+	if containsShared {
+		flags |= containsSharedFlag
+	}
+
+	binary.BigEndian.PutUint64(br[2:], used|flags)
+	binary.BigEndian.PutUint64(br[2+8:], uint64(lowest))
+
+	return nil
+}
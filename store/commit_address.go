@@ -2,75 +2,81 @@ package store
 
 import (
 	"encoding/binary"
-	"os"
 
-	"github.com/edsrzf/mmap-go"
 	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
 )
 
+const commitAddressName = "commitAddress"
+
 type commitAddress struct {
-	f    *os.File
-	MMap mmap.MMap
+	f    File
+	MMap []byte
 }
 
-func openCommitAddress(fileName string) (*commitAddress, error) {
-	f, err := os.OpenFile(fileName, os.O_RDWR|os.O_CREATE, 0600)
+// openCommitAddress opens the commit address of the backend, creating it if needed.
+func openCommitAddress(b Backend) (*commitAddress, error) {
+	found, err := hasFile(b, commitAddressName)
 	if err != nil {
-		return nil, errors.Wrapf(err, "while opening file %q", fileName)
+		return nil, err
 	}
 
-	fs, err := f.Stat()
+	var f File
+	if found {
+		f, err = b.Open(commitAddressName, 8, false)
+	} else {
+		f, err = b.Create(commitAddressName, 8)
+	}
 	if err != nil {
-		return nil, errors.Wrapf(err, "while getting fstat of %q", fileName)
+		return nil, err
 	}
 
-	switch s := fs.Size(); s {
+	switch s := f.Size(); s {
 	case 0:
-		b := make([]byte, 8)
-		_, err = f.Write(b)
+		err = f.Truncate(8)
 		if err != nil {
+			f.Close()
 			return nil, errors.Wrap(err, "while writing nil commit address")
 		}
 	case 8:
 		// all good
 	default:
-		return nil, errors.Errorf("file %s bas %d bytes - expected 0 or 8", fileName, s)
-	}
-
-	mm, err := mmap.MapRegion(f, 8, mmap.RDWR, 0, 0)
-	if err != nil {
 		f.Close()
-		return nil, errors.Wrapf(err, "while mmaping file %q", fileName)
+		return nil, errors.Errorf("file %s bas %d bytes - expected 0 or 8", f.Name(), s)
 	}
 
-	err = unix.Madvise(mm, unix.MADV_RANDOM)
+	return &commitAddress{f: f, MMap: f.Bytes()}, nil
+}
+
+// openCommitAddressReadOnly opens an existing commit address for reading.
+func openCommitAddressReadOnly(b Backend) (*commitAddress, error) {
+	f, err := b.Open(commitAddressName, 8, true)
 	if err != nil {
-		return nil, errors.Wrapf(err, "while setting madvise to random for segment file %q", fileName)
+		return nil, err
 	}
 
-	s := &commitAddress{
-		f:    f,
-		MMap: mm,
+	if f.Size() != 8 {
+		f.Close()
+		return nil, errors.Errorf("file %s bas %d bytes - expected 8", f.Name(), f.Size())
 	}
 
-	return s, nil
-
+	return &commitAddress{f: f, MMap: f.Bytes()}, nil
 }
 
-func (c *commitAddress) close() error {
-	err := c.MMap.Unmap()
+func hasFile(b Backend, name string) (bool, error) {
+	names, err := b.Names()
 	if err != nil {
-		return errors.Wrapf(err, "while unmmaping %q", c.f.Name())
+		return false, err
 	}
-
-	err = c.f.Close()
-	if err != nil {
-		return errors.Wrapf(err, "while closing %s", c.f.Name())
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
 	}
+	return false, nil
+}
 
-	return nil
-
+func (c *commitAddress) close() error {
+	return c.f.Close()
 }
 
 func (c *commitAddress) address() Address {
@@ -79,5 +85,5 @@ func (c *commitAddress) address() Address {
 
 func (c *commitAddress) setAddress(a Address) {
 	binary.BigEndian.PutUint64(c.MMap, uint64(a))
-	c.MMap.Flush()
+	c.f.Flush()
 }
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/edsrzf/mmap-go"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// fileBackend keeps files in a directory and maps them into memory.
+type fileBackend struct {
+	dir string
+}
+
+// NewFileBackend creates a backend keeping files in the directory dir.
+func NewFileBackend(dir string) Backend {
+	return &fileBackend{dir: dir}
+}
+
+func (b *fileBackend) Names() ([]string, error) {
+	files, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading dir %s", b.dir)
+	}
+
+	names := []string{}
+	for _, f := range files {
+		if f.Mode().IsRegular() {
+			names = append(names, f.Name())
+		}
+	}
+
+	return names, nil
+}
+
+func (b *fileBackend) Create(name string, maxSize uint64) (File, error) {
+	fileName := filepath.Join(b.dir, name)
+
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening file %q", fileName)
+	}
+
+	return mapFile(f, maxSize, mmap.RDWR, 0)
+}
+
+func (b *fileBackend) Open(name string, maxSize uint64, readOnly bool) (File, error) {
+	fileName := filepath.Join(b.dir, name)
+
+	flag, prot := os.O_RDWR, mmap.RDWR
+	if readOnly {
+		flag, prot = os.O_RDONLY, mmap.RDONLY
+	}
+
+	f, err := os.OpenFile(fileName, flag, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening file %q", fileName)
+	}
+
+	fs, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "while getting fstat of %q", fileName)
+	}
+
+	return mapFile(f, maxSize, prot, uint64(fs.Size()))
+}
+
+func (b *fileBackend) Remove(name string) error {
+	fileName := filepath.Join(b.dir, name)
+	err := os.Remove(fileName)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "while removing %q", fileName)
+	}
+	return nil
+}
+
+func (b *fileBackend) Close() error {
+	return nil
+}
+
+type mappedFile struct {
+	f    *os.File
+	mm   mmap.MMap
+	size uint64
+}
+
+func mapFile(f *os.File, maxSize uint64, prot int, size uint64) (*mappedFile, error) {
+	mm, err := mmap.MapRegion(f, int(maxSize), prot, 0, 0)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "while mmaping file %q", f.Name())
+	}
+
+	err = unix.Madvise(mm, unix.MADV_RANDOM)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while setting madvise to random for file %q", f.Name())
+	}
+
+	return &mappedFile{f: f, mm: mm, size: size}, nil
+}
+
+func (m *mappedFile) Name() string {
+	return filepath.Base(m.f.Name())
+}
+
+func (m *mappedFile) Bytes() []byte {
+	return m.mm
+}
+
+func (m *mappedFile) Size() uint64 {
+	return m.size
+}
+
+func (m *mappedFile) Truncate(size uint64) error {
+	err := m.f.Truncate(int64(size))
+	if err != nil {
+		return errors.Wrapf(err, "while truncating %q to %d bytes", m.f.Name(), size)
+	}
+	m.size = size
+	return nil
+}
+
+func (m *mappedFile) Flush() error {
+	return m.mm.Flush()
+}
+
+func (m *mappedFile) Close() error {
+	err := m.mm.Unmap()
+	if err != nil {
+		return errors.Wrapf(err, "while unmmaping %q", m.f.Name())
+	}
+
+	err = m.f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "while closing %s", m.f.Name())
+	}
+
+	return nil
+}
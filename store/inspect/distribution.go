@@ -0,0 +1,59 @@
+package inspect
+
+import (
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// Bucket counts the blocks with the lowest descendant address in [From, To).
+type Bucket struct {
+	From   store.Address
+	To     store.Address
+	Blocks int
+	Bytes  uint64
+}
+
+// LowestDescendantDistribution splits the address range used by the blocks reachable
+// from root into n equally sized buckets and counts the blocks by their lowest descendant address.
+// Compaction copies all blocks with the lowest descendant address below a threshold,
+// so the distribution shows how much would be copied by moving the threshold.
+func LowestDescendantDistribution(r store.Reader, root store.Address, n int) ([]Bucket, error) {
+	if n <= 0 {
+		return nil, errors.New("number of buckets must be positive")
+	}
+
+	rb, err := r.GetBlock(root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while getting root block %d", root)
+	}
+
+	from := rb.GetLowestDescendentAddress()
+	to := root + store.Address(len(rb))
+
+	width := (uint64(to-from) + uint64(n) - 1) / uint64(n)
+	if width == 0 {
+		width = 1
+	}
+
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].From = from + store.Address(uint64(i)*width)
+		buckets[i].To = buckets[i].From + store.Address(width)
+	}
+
+	err = Walk(r, root, func(a store.Address, br store.BlockReader, depth int) error {
+		lda := br.GetLowestDescendentAddress()
+		if lda < from || lda >= to {
+			return errors.Errorf("block %d has lowest descendant %d outside of [%d, %d)", a, lda, from, to)
+		}
+		b := &buckets[uint64(lda-from)/width]
+		b.Blocks++
+		b.Bytes += uint64(len(br))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,131 @@
+// Package inspect contains tools for looking at the blocks of a store
+// when debugging the store itself.
+package inspect
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/pkg/errors"
+)
+
+// Walk calls f with every block reachable from root, parents before their children.
+// Blocks referenced from more than one parent are visited once.
+// depth is the number of blocks between root and the visited block.
+func Walk(r store.Reader, root store.Address, f func(a store.Address, br store.BlockReader, depth int) error) error {
+	seen := map[store.Address]bool{}
+
+	var walk func(a store.Address, depth int) error
+	walk = func(a store.Address, depth int) error {
+		if a == store.NilAddress || seen[a] {
+			return nil
+		}
+		seen[a] = true
+
+		br, err := r.GetBlock(a)
+		if err != nil {
+			return errors.Wrapf(err, "while getting block %d", a)
+		}
+
+		err = f(a, br, depth)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < br.NumberOfChildren(); i++ {
+			err = walk(br.GetChildAddress(i), depth+1)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return walk(root, 0)
+}
+
+// DumpBlock writes the header fields, children and the hex dump of the data of the block at a.
+func DumpBlock(w io.Writer, r store.Reader, a store.Address) error {
+	br, err := r.GetBlock(a)
+	if err != nil {
+		return errors.Wrapf(err, "while getting block %d", a)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(tw, "address:\t%d\n", a)
+	fmt.Fprintf(tw, "type:\t%s\n", br.Type())
+	fmt.Fprintf(tw, "block size:\t%d\n", br.BlockSize())
+	fmt.Fprintf(tw, "used data size:\t%d\n", br.GetUsedDataSize())
+	fmt.Fprintf(tw, "lowest descendant:\t%d\n", br.GetLowestDescendentAddress())
+	fmt.Fprintf(tw, "shared:\t%t\n", br.Shared())
+	fmt.Fprintf(tw, "contains shared:\t%t\n", br.ContainsShared())
+	fmt.Fprintf(tw, "children:\t%d\n", br.NumberOfChildren())
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		fmt.Fprintf(tw, "  %d:\t%d\n", i, br.GetChildAddress(i))
+	}
+	fmt.Fprintf(tw, "data:\t%d bytes\n", len(br.GetData()))
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, hex.Dump(br.GetData()))
+	return err
+}
+
+// WriteDOT writes the graph of blocks reachable from root in the Graphviz DOT format.
+// Blocks deeper than maxDepth are not included, maxDepth < 0 includes all blocks.
+func WriteDOT(w io.Writer, r store.Reader, root store.Address, maxDepth int) error {
+	_, err := fmt.Fprintln(w, "digraph blocks {")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, "  node [shape=record];")
+	if err != nil {
+		return err
+	}
+
+	err = Walk(r, root, func(a store.Address, br store.BlockReader, depth int) error {
+		if maxDepth >= 0 && depth > maxDepth {
+			return nil
+		}
+
+		style := ""
+		if br.Shared() {
+			style = ", style=bold"
+		}
+
+		_, err := fmt.Fprintf(w, "  b%d [label=\"{%d|%s|size %d|lowest %d}\"%s];\n", a, a, br.Type(), br.BlockSize(), br.GetLowestDescendentAddress(), style)
+		if err != nil {
+			return err
+		}
+
+		if maxDepth >= 0 && depth == maxDepth {
+			return nil
+		}
+
+		for i := 0; i < br.NumberOfChildren(); i++ {
+			ca := br.GetChildAddress(i)
+			if ca == store.NilAddress {
+				continue
+			}
+			_, err = fmt.Fprintf(w, "  b%d -> b%d [label=\"%d\"];\n", a, ca, i)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, "}")
+	return err
+}
@@ -0,0 +1,118 @@
+package inspect_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/draganm/chaintrackdb/store"
+	"github.com/draganm/chaintrackdb/store/inspect"
+	"github.com/stretchr/testify/require"
+)
+
+func newStore(t *testing.T) (*store.Store, store.Address, func()) {
+	td, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+
+	tx, _, err := st.NewWriteTransaction(context.Background())
+	require.NoError(t, err)
+
+	leaf, err := tx.AppendBlock(store.TypeDataLeaf, 0, 4)
+	require.NoError(t, err)
+	copy(leaf.Data, []byte("leaf"))
+
+	root, err := tx.AppendBlock(store.TypeDataNode, 2, 0)
+	require.NoError(t, err)
+	require.NoError(t, root.SetChild(0, leaf.Address))
+	require.NoError(t, root.SetChild(1, leaf.Address))
+
+	ra, err := tx.Commit(root.Address)
+	require.NoError(t, err)
+
+	return st, ra, func() {
+		st.Close()
+		os.RemoveAll(td)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	st, root, cleanup := newStore(t)
+	defer cleanup()
+
+	t.Run("when I walk the blocks", func(t *testing.T) {
+		depths := []int{}
+		err := inspect.Walk(st, root, func(a store.Address, br store.BlockReader, depth int) error {
+			depths = append(depths, depth)
+			return nil
+		})
+		require.NoError(t, err)
+
+		t.Run("then every block should be visited once", func(t *testing.T) {
+			require.Equal(t, []int{0, 1}, depths)
+		})
+	})
+}
+
+func TestDumpBlock(t *testing.T) {
+	st, root, cleanup := newStore(t)
+	defer cleanup()
+
+	t.Run("when I dump the root block", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := inspect.DumpBlock(buf, st, root)
+		require.NoError(t, err)
+
+		t.Run("then the header fields should be written", func(t *testing.T) {
+			require.Contains(t, buf.String(), "type:              DataNode\n")
+			require.Contains(t, buf.String(), "children:          2\n")
+		})
+	})
+}
+
+func TestWriteDOT(t *testing.T) {
+	st, root, cleanup := newStore(t)
+	defer cleanup()
+
+	t.Run("when I write the graph", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		err := inspect.WriteDOT(buf, st, root, -1)
+		require.NoError(t, err)
+
+		br, err := st.GetBlock(root)
+		require.NoError(t, err)
+		leaf := br.GetChildAddress(0)
+
+		t.Run("then it should contain both edges to the leaf", func(t *testing.T) {
+			require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte(" -> b"+addrString(leaf))))
+		})
+	})
+}
+
+func TestLowestDescendantDistribution(t *testing.T) {
+	st, root, cleanup := newStore(t)
+	defer cleanup()
+
+	t.Run("when I get the distribution", func(t *testing.T) {
+		buckets, err := inspect.LowestDescendantDistribution(st, root, 4)
+		require.NoError(t, err)
+
+		t.Run("then all blocks should be counted", func(t *testing.T) {
+			blocks := 0
+			for _, b := range buckets {
+				blocks += b.Blocks
+			}
+			require.Equal(t, 2, blocks)
+			require.Equal(t, 2, buckets[0].Blocks)
+		})
+	})
+}
+
+func addrString(a store.Address) string {
+	return strconv.FormatUint(uint64(a), 10)
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// memoryBackend keeps files in anonymous memory mappings.
+// Unlike slices of the Go heap, mappings reserve the maximal size of a file
+// without allocating it, so the memory of a file never moves when it grows.
+type memoryBackend struct {
+	mu          *sync.Mutex
+	files       map[string]*memoryFile
+	maxFileSize uint64
+}
+
+// DefaultMaxMemoryFileSize is the default maximal size of a file of a memory backend.
+const DefaultMaxMemoryFileSize = 1024 * 1024 * 1024
+
+// NewMemoryBackend creates a backend keeping files in memory.
+// Every file reserves maxFileSize bytes of address space, which limits the size of
+// a segment and of the blocks written by a single transaction.
+// Memory is allocated only when it's written, but on hosts with strict overcommit
+// (vm.overcommit_memory=2) every file counts with its full maxFileSize against the commit limit.
+// All files are released when the backend is closed.
+func NewMemoryBackend(maxFileSize uint64) Backend {
+	return &memoryBackend{
+		mu:          new(sync.Mutex),
+		files:       map[string]*memoryFile{},
+		maxFileSize: maxFileSize,
+	}
+}
+
+func (b *memoryBackend) Names() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.files))
+	for n := range b.files {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (b *memoryBackend) Create(name string, maxSize uint64) (File, error) {
+	if maxSize > b.maxFileSize {
+		maxSize = b.maxFileSize
+	}
+
+	mem, err := unix.Mmap(-1, 0, int(maxSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANON|unix.MAP_NORESERVE)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while mapping %d bytes of memory for %q", maxSize, name)
+	}
+
+	f := &memoryFile{name: name, mem: mem, mu: b.mu}
+
+	b.mu.Lock()
+	old := b.files[name]
+	b.files[name] = f
+	b.mu.Unlock()
+
+	if old != nil {
+		err = old.remove()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (b *memoryBackend) Open(name string, maxSize uint64, readOnly bool) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f := b.files[name]
+	if f == nil {
+		return nil, errors.Wrapf(os.ErrNotExist, "while opening %q", name)
+	}
+
+	f.closed = false
+
+	return f, nil
+}
+
+func (b *memoryBackend) Remove(name string) error {
+	b.mu.Lock()
+	f := b.files[name]
+	delete(b.files, name)
+	b.mu.Unlock()
+
+	if f == nil {
+		return nil
+	}
+
+	return f.remove()
+}
+
+func (b *memoryBackend) Close() error {
+	b.mu.Lock()
+	files := b.files
+	b.files = map[string]*memoryFile{}
+	b.mu.Unlock()
+
+	for _, f := range files {
+		err := f.remove()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// memoryFile is released once it has been both closed and removed.
+type memoryFile struct {
+	name    string
+	mem     []byte
+	size    uint64
+	mu      *sync.Mutex
+	closed  bool
+	removed bool
+}
+
+func (m *memoryFile) Name() string {
+	return m.name
+}
+
+func (m *memoryFile) Bytes() []byte {
+	return m.mem
+}
+
+func (m *memoryFile) Size() uint64 {
+	return m.size
+}
+
+func (m *memoryFile) Truncate(size uint64) error {
+	if size > uint64(len(m.mem)) {
+		return errors.Errorf("%q can't grow beyond %d bytes", m.name, len(m.mem))
+	}
+
+	// like a file, a grown file must not contain data written before it was shrunk
+	for i := size; i < m.size; i++ {
+		m.mem[i] = 0
+	}
+
+	m.size = size
+
+	return nil
+}
+
+func (m *memoryFile) Flush() error {
+	return nil
+}
+
+func (m *memoryFile) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	release := m.removed
+	m.mu.Unlock()
+
+	if release {
+		return m.release()
+	}
+
+	return nil
+}
+
+func (m *memoryFile) remove() error {
+	m.mu.Lock()
+	m.removed = true
+	release := m.closed
+	m.mu.Unlock()
+
+	if release {
+		return m.release()
+	}
+
+	return nil
+}
+
+func (m *memoryFile) release() error {
+	m.mu.Lock()
+	mem := m.mem
+	m.mem = nil
+	m.mu.Unlock()
+
+	if mem == nil {
+		return nil
+	}
+
+	err := unix.Munmap(mem)
+	if err != nil {
+		return errors.Wrapf(err, "while unmapping memory of %q", m.name)
+	}
+
+	return nil
+}
@@ -5,9 +5,19 @@ type Reader interface {
 }
 
 type ReadTransaction struct {
-	s *Store
+	s  *Store
+	id uint64
 }
 
 func (r *ReadTransaction) GetBlock(a Address) (BlockReader, error) {
 	return r.s.GetBlock(a)
 }
+
+// Close releases the root pinned by the transaction.
+// Calling Close on a transaction that is not pinned is a no-op.
+func (r *ReadTransaction) Close() {
+	if r.id != 0 {
+		r.s.txFinished(r.id)
+		r.id = 0
+	}
+}
@@ -0,0 +1,298 @@
+package store
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Frame is a part of the changes made by a commit, sent from a leader to its replicas.
+// Applying all frames of a commit appends the same blocks at the same addresses
+// as on the leader, so the replica ends up with the same roots.
+type Frame struct {
+	// Reset is true if the replica has to discard all its blocks before applying the frame.
+	Reset bool
+	// SegmentStart is the start address of the leader's segment containing the blocks.
+	SegmentStart Address
+	// Address is the address of the first appended byte.
+	Address Address
+	// Data are the appended bytes.
+	Data []byte
+	// Root is the commited root after the frame is applied,
+	// NilAddress if more frames of the same commit follow.
+	Root Address
+}
+
+// maxFrameData is the maximal number of bytes sent in one frame.
+const maxFrameData = 1024 * 1024
+
+// subscriberBuffer is the number of commits a replica can lag behind before it is disconnected.
+const subscriberBuffer = 1024
+
+// ErrReplicaTooSlow is returned by Replicate when the replica can't keep up with the commits.
+var ErrReplicaTooSlow = errors.New("replica is too slow")
+
+// ErrReplicaDiverged is returned when applying a frame that doesn't continue the blocks of the replica.
+var ErrReplicaDiverged = errors.New("replica has diverged from the leader")
+
+type subscriber struct {
+	frames chan []Frame
+}
+
+// Replicate calls send with frames bringing a replica with blocks up to the address from
+// to the last commited root, followed by frames of every commit until ctx is done.
+// If the replica can't be brought up to date by appending blocks, the first frame resets it.
+func (s *Store) Replicate(ctx context.Context, from Address, send func(Frame) error) error {
+	sub := &subscriber{frames: make(chan []Frame, subscriberBuffer)}
+
+	s.commitMu.Lock()
+	id, root := s.pinRoot()
+	end := s.nextAddress()
+	if s.subscribers == nil {
+		s.subscribers = map[*subscriber]bool{}
+	}
+	s.subscribers[sub] = true
+	s.commitMu.Unlock()
+
+	defer func() {
+		s.commitMu.Lock()
+		delete(s.subscribers, sub)
+		s.commitMu.Unlock()
+	}()
+
+	// blocks of the pinned root can be sent without copying them
+	err := func() error {
+		defer s.txFinished(id)
+
+		rb, err := s.GetBlock(root)
+		if err != nil {
+			return errors.Wrap(err, "while reading root block")
+		}
+
+		lowest := rb.GetLowestDescendentAddress()
+		reset := from < lowest || from > end
+
+		if reset {
+			from = lowest
+		}
+
+		for _, f := range s.frames(from, end, root, reset, false) {
+			err = send(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case frames, ok := <-sub.frames:
+			if !ok {
+				return ErrReplicaTooSlow
+			}
+			for _, f := range frames {
+				err = send(f)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// frames returns frames with the bytes of all segments in [from, end) commiting root.
+// Must be called with commitMu held or with blocks in the range pinned.
+func (s *Store) frames(from, end, root Address, reset, copyData bool) []Frame {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	frames := []Frame{}
+
+	for _, seg := range s.segments {
+		start := seg.startAddress()
+		if start < from {
+			start = from
+		}
+
+		segEnd := seg.endAddress()
+		if segEnd > end {
+			segEnd = end
+		}
+
+		for a := start; a < segEnd; a += maxFrameData {
+			to := a + maxFrameData
+			if to > segEnd {
+				to = segEnd
+			}
+
+			d := seg.bytes(a, to)
+			if copyData {
+				d = append([]byte(nil), d...)
+			}
+
+			frames = append(frames, Frame{
+				SegmentStart: seg.startAddress(),
+				Address:      a,
+				Data:         d,
+			})
+		}
+	}
+
+	if len(frames) == 0 {
+		frames = append(frames, Frame{})
+	}
+
+	frames[0].Reset = reset
+	frames[len(frames)-1].Root = root
+
+	return frames
+}
+
+// publish sends the blocks appended since from to all replicas.
+// Must be called with commitMu held.
+func (s *Store) publish(from, root Address) {
+	if len(s.subscribers) == 0 {
+		return
+	}
+
+	frames := s.frames(from, s.nextAddress(), root, false, true)
+
+	for sub := range s.subscribers {
+		select {
+		case sub.frames <- frames:
+		default:
+			close(sub.frames)
+			delete(s.subscribers, sub)
+		}
+	}
+}
+
+// NextAddress returns the address of the next block appended by a commit.
+// A replica passes it to Replicate of the leader.
+func (s *Store) NextAddress() Address {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextAddress()
+}
+
+// ApplyFrame appends the blocks of a frame sent by the leader's Replicate.
+// Once a frame has been applied, the store is a replica and
+// write transactions fail with ErrReadOnly until the store is reopened.
+func (s *Store) ApplyFrame(f Frame) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	s.mu.Lock()
+	s.replica = true
+	s.mu.Unlock()
+
+	if f.Reset {
+		err := s.reset()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(f.Data) > 0 {
+		err := s.appendFrameData(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.Root == NilAddress {
+		return nil
+	}
+
+	_, err := s.GetBlock(f.Root)
+	if err != nil {
+		return errors.Wrapf(err, "while reading replicated root %d", f.Root)
+	}
+
+	s.lastCommitAddress.setAddress(f.Root)
+
+	s.mu.Lock()
+	s.root = f.Root
+	s.mu.Unlock()
+
+	return s.removeUnusedSegments()
+}
+
+// reset removes all segments of the store.
+func (s *Store) reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.activeTransactions) > 0 {
+		return errors.New("replica can't be reset while transactions are in progress")
+	}
+
+	for _, seg := range s.segments {
+		err := seg.closeAndRemove()
+		if err != nil {
+			return err
+		}
+	}
+
+	s.segments = nil
+
+	// the old root is gone, the store is empty until the next root is applied
+	s.lastCommitAddress.setAddress(NilAddress)
+
+	return nil
+}
+
+func (s *Store) appendFrameData(f Frame) error {
+	s.mu.RLock()
+	needsSegment := len(s.segments) == 0 || s.lastSegment().startAddress() < f.SegmentStart
+	s.mu.RUnlock()
+
+	if needsSegment {
+		start := f.Address
+		if len(s.segments) > 0 {
+			start = f.SegmentStart
+			if s.nextAddress() != start {
+				return errors.Wrapf(ErrReplicaDiverged, "segment starts at %d, last block ends at %d", start, s.nextAddress())
+			}
+		}
+
+		seg, err := createSegment(s.backend, segmentName(start), MaxSegmentSize, start)
+		if err != nil {
+			return errors.Wrapf(err, "while creating segment at %d", start)
+		}
+
+		s.mu.Lock()
+		s.segments = append(s.segments, seg)
+		s.mu.Unlock()
+	}
+
+	if s.nextAddress() != f.Address {
+		return errors.Wrapf(ErrReplicaDiverged, "frame starts at %d, last block ends at %d", f.Address, s.nextAddress())
+	}
+
+	_, d, err := s.lastSegment().appendBlock(uint64(len(f.Data)))
+	if err != nil {
+		return errors.Wrap(err, "while appending frame data")
+	}
+
+	copy(d, f.Data)
+
+	return nil
+}
+
+func (s *Store) isReplica() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.replica
+}
@@ -2,11 +2,8 @@ package store
 
 import (
 	"encoding/binary"
-	"os"
 
-	"github.com/edsrzf/mmap-go"
 	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
 )
 
 // segment layout
@@ -16,9 +13,9 @@ import (
 // data - rest
 
 type segment struct {
-	f           *os.File
-	MMap        mmap.MMap
-	currentSize uint64
+	b    Backend
+	f    File
+	MMap []byte
 }
 
 func (s *segment) startAddress() Address {
@@ -57,84 +54,49 @@ func (s *segment) getBlock(a Address) (BlockReader, error) {
 	return NewBlockReader(s.MMap[idx:])
 }
 
-func createSegment(fileName string, maxSize uint64, offset Address) (*segment, error) {
+func createSegment(b Backend, name string, maxSize uint64, offset Address) (*segment, error) {
 
 	if offset == 0 {
 		return nil, errors.New("offset must be > 0")
 	}
 
-	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_RDWR, 0600)
+	f, err := b.Create(name, maxSize)
 	if err != nil {
-		return nil, errors.Wrapf(err, "while opening file %q", fileName)
+		return nil, err
 	}
 
-	addressAndNextBlockOffset := make([]byte, 16)
-
-	binary.BigEndian.PutUint64(addressAndNextBlockOffset, uint64(offset))
-	binary.BigEndian.PutUint64(addressAndNextBlockOffset[8:], 16)
-
-	_, err = f.Write(addressAndNextBlockOffset)
-	if err != nil {
-		return nil, errors.Wrapf(err, "while appending data to %q", fileName)
-	}
-
-	mm, err := mmap.MapRegion(f, int(maxSize), mmap.RDWR, 0, 0)
+	err = f.Truncate(16)
 	if err != nil {
 		f.Close()
-		return nil, errors.Wrapf(err, "while mmaping file %q", fileName)
+		return nil, err
 	}
 
-	err = unix.Madvise(mm, unix.MADV_RANDOM)
-	if err != nil {
-		return nil, errors.Wrapf(err, "while setting madvise to random for segment file %q", fileName)
-	}
+	mm := f.Bytes()
 
-	s := &segment{
-		f:           f,
-		MMap:        mm,
-		currentSize: uint64(len(addressAndNextBlockOffset)),
-	}
+	binary.BigEndian.PutUint64(mm, uint64(offset))
+	binary.BigEndian.PutUint64(mm[8:], 16)
 
-	return s, nil
+	return &segment{b: b, f: f, MMap: mm}, nil
 
 }
 
-func openSegment(fileName string, maxSize uint64) (*segment, error) {
+// openSegment opens an existing segment.
+// Blocks of a segment opened read only can't be appended.
+func openSegment(b Backend, name string, maxSize uint64, readOnly bool) (*segment, error) {
 
-	f, err := os.OpenFile(fileName, os.O_RDWR, 0600)
+	f, err := b.Open(name, maxSize, readOnly)
 	if err != nil {
-		return nil, errors.Wrapf(err, "while opening file %q", fileName)
+		return nil, err
 	}
 
-	fs, err := f.Stat()
-	if err != nil {
-		return nil, errors.Wrapf(err, "while getting fstat of %q", fileName)
-	}
-
-	if fs.Size() < 16 {
-		return nil, errors.Errorf("file %s is shorter than 16 bytes", fileName)
-	}
-
-	mm, err := mmap.MapRegion(f, int(maxSize), mmap.RDWR, 0, 0)
-	if err != nil {
+	if f.Size() < 16 {
 		f.Close()
-		return nil, errors.Wrapf(err, "while mmaping file %q", fileName)
-	}
-
-	err = unix.Madvise(mm, unix.MADV_RANDOM)
-	if err != nil {
-		return nil, errors.Wrapf(err, "while setting madvise to random for segment file %q", fileName)
+		return nil, errors.Errorf("file %s is shorter than 16 bytes", name)
 	}
 
 	// TODO: check the last offset
 
-	s := &segment{
-		f:           f,
-		MMap:        mm,
-		currentSize: uint64(fs.Size()),
-	}
-
-	return s, nil
+	return &segment{b: b, f: f, MMap: f.Bytes()}, nil
 
 }
 
@@ -143,26 +105,16 @@ func (s *segment) closeAndRemove() error {
 	if err != nil {
 		return errors.Wrap(err, "while closing segment")
 	}
-	err = os.Remove(s.f.Name())
-	if err != nil {
-		return errors.Wrapf(err, "while removing %q", s.f.Name())
-	}
-	return nil
+	return s.remove()
 }
 
-func (s *segment) close() error {
-	err := s.MMap.Unmap()
-	if err != nil {
-		return errors.Wrapf(err, "while unmmaping %q", s.f.Name())
-	}
-
-	err = s.f.Close()
-	if err != nil {
-		return errors.Wrapf(err, "while closing %s", s.f.Name())
-	}
-
-	return nil
+// remove removes the segment file. Segment stays mapped until it is closed.
+func (s *segment) remove() error {
+	return s.b.Remove(s.f.Name())
+}
 
+func (s *segment) close() error {
+	return s.f.Close()
 }
 
 func (s *segment) appendBlock(blockSize uint64) (Address, []byte, error) {
@@ -185,27 +137,42 @@ const minGrowSize = 16 * 1024 * 1024
 
 func (s *segment) ensureSpace(len uint64) error {
 
-	if s.currentSize-s.nextBlockOffset() >= len {
+	currentSize := s.f.Size()
+
+	if currentSize-s.nextBlockOffset() >= len {
 		return nil
 	}
 
-	growsNeeded := (len - (s.nextBlockOffset() - s.currentSize)) / minGrowSize
+	growsNeeded := (len - (s.nextBlockOffset() - currentSize)) / minGrowSize
 
-	if (len-(s.nextBlockOffset()-s.currentSize))%minGrowSize > 0 {
+	if (len-(s.nextBlockOffset()-currentSize))%minGrowSize > 0 {
 		growsNeeded++
 	}
 
 	growBy := growsNeeded * minGrowSize
 
-	err := s.f.Truncate(int64(s.currentSize + growBy))
+	err := s.f.Truncate(currentSize + growBy)
 	if err != nil {
-		return errors.Wrapf(err, "wile growing %q to %d bytes", s.f.Name(), int64(s.currentSize+growBy))
+		return errors.Wrapf(err, "wile growing %q to %d bytes", s.f.Name(), currentSize+growBy)
 	}
 
-	s.currentSize += growBy
+	return nil
 
+}
+
+// truncate discards all blocks starting at or after the address a.
+func (s *segment) truncate(a Address) error {
+	if a < s.startAddress() || a > s.endAddress() {
+		return errors.Errorf("address %d is outside of the segment", a)
+	}
+	binary.BigEndian.PutUint64(s.MMap[8:], uint64(a-s.startAddress())+16)
 	return nil
+}
 
+// bytes returns the content of the segment in the address range [from, to).
+func (s *segment) bytes(from, to Address) []byte {
+	start := uint64(from-s.startAddress()) + 16
+	return s.MMap[start : start+uint64(to-from)]
 }
 
 func (s *segment) nextBlockOffset() uint64 {
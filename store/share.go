@@ -0,0 +1,91 @@
+package store
+
+import "github.com/pkg/errors"
+
+// Share returns the address of a block that can be referenced from more than one parent
+// with the same content as the block at a.
+// If the block is not shared already, a shallow copy of it marked as shared is appended.
+// All parents of the block must reference the returned address instead of a
+// for the live size of the tree to be correct.
+func Share(rw ReaderWriter, a Address) (Address, error) {
+	br, err := rw.GetBlock(a)
+	if err != nil {
+		return NilAddress, errors.Wrapf(err, "while getting block %d", a)
+	}
+
+	if br.Shared() {
+		return a, nil
+	}
+
+	data := br.GetData()
+
+	bw, err := rw.AppendBlock(br.Type(), br.NumberOfChildren(), len(data))
+	if err != nil {
+		return NilAddress, errors.Wrap(err, "while appending shared block")
+	}
+
+	copy(bw.Data, data)
+
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		err = bw.SetChild(i, br.GetChildAddress(i))
+		if err != nil {
+			return NilAddress, errors.Wrap(err, "while setting child of shared block")
+		}
+	}
+
+	bw.SetShared()
+
+	return bw.Address, nil
+}
+
+// LiveSize returns the total size of all blocks reachable from root.
+// Unlike GetUsedDataSize, blocks referenced from more than one parent are counted once.
+// Only subtrees containing shared blocks are walked.
+func LiveSize(r Reader, root Address) (uint64, error) {
+	l := &liveSizeCounter{
+		r:    r,
+		seen: map[Address]bool{},
+	}
+	return l.size(root, false)
+}
+
+type liveSizeCounter struct {
+	r    Reader
+	seen map[Address]bool
+}
+
+func (l *liveSizeCounter) size(a Address, inShared bool) (uint64, error) {
+	if a == NilAddress {
+		return 0, nil
+	}
+
+	br, err := l.r.GetBlock(a)
+	if err != nil {
+		return 0, errors.Wrapf(err, "while getting block %d", a)
+	}
+
+	inShared = inShared || br.Shared()
+
+	// no block in the subtree has more than one parent
+	if !inShared && !br.ContainsShared() {
+		return br.GetUsedDataSize(), nil
+	}
+
+	// descendants of a shared block can be referenced by copies of it
+	if l.seen[a] {
+		return 0, nil
+	}
+	l.seen[a] = true
+
+	total := uint64(len(br))
+
+	for i := 0; i < br.NumberOfChildren(); i++ {
+		cs, err := l.size(br.GetChildAddress(i), inShared)
+		if err != nil {
+			return 0, err
+		}
+		total += cs
+	}
+
+	return total, nil
+}
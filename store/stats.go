@@ -0,0 +1,72 @@
+package store
+
+import "github.com/pkg/errors"
+
+// Stats describes the space used by the last commited root.
+type Stats struct {
+	Root Address
+	// LowestAddress is the lowest address of a block reachable from the root.
+	LowestAddress Address
+	// HighestAddress is the address of the end of the root block.
+	HighestAddress Address
+	// OccupiedBytes is the size of the address range between the lowest and the highest address.
+	OccupiedBytes uint64
+	// UsedBytes is the used data size recorded in the root block.
+	// Blocks shared by more than one parent are counted once for every parent.
+	UsedBytes uint64
+	// LiveBytes is the total size of all blocks reachable from the root.
+	LiveBytes uint64
+}
+
+// Stats returns space usage of the last commited root.
+func (s *Store) Stats() (Stats, error) {
+	id, root := s.pinRoot()
+	defer s.txFinished(id)
+
+	br, err := s.GetBlock(root)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "while reading root block")
+	}
+
+	live, err := LiveSize(s, root)
+	if err != nil {
+		return Stats{}, errors.Wrap(err, "while calculating live size")
+	}
+
+	lowest := br.GetLowestDescendentAddress()
+	highest := root + Address(len(br))
+
+	return Stats{
+		Root:           root,
+		LowestAddress:  lowest,
+		HighestAddress: highest,
+		OccupiedBytes:  uint64(highest - lowest),
+		UsedBytes:      br.GetUsedDataSize(),
+		LiveBytes:      live,
+	}, nil
+}
+
+// SegmentInfo describes a segment file of the store.
+type SegmentInfo struct {
+	Name string
+	// StartAddress is the address of the first block in the segment.
+	StartAddress Address
+	// EndAddress is the address following the last block in the segment.
+	EndAddress Address
+}
+
+// Segments returns the segments of the store, ordered by their addresses.
+func (s *Store) Segments() []SegmentInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	infos := make([]SegmentInfo, len(s.segments))
+	for i, seg := range s.segments {
+		infos[i] = SegmentInfo{
+			Name:         seg.f.Name(),
+			StartAddress: seg.startAddress(),
+			EndAddress:   seg.endAddress(),
+		}
+	}
+	return infos
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
 	"regexp"
 	"sort"
 	"sync"
@@ -14,44 +12,73 @@ import (
 )
 
 type Store struct {
-	dir                        string
-	segments                   []*segment
-	mu                         *sync.Mutex
-	lastCommitAddress          *commitAddress
-	readerTransactions         int
-	writeTransactionInProgress bool
-	writeTransactionCond       *sync.Cond
+	backend            Backend
+	id                 StoreID
+	segments           []*segment
+	mu                 *sync.RWMutex
+	commitMu           *sync.Mutex
+	lastCommitAddress  *commitAddress
+	root               Address
+	readerTransactions int
+	nextTransactionID  uint64
+	activeTransactions map[uint64]Address
+	readOnly           bool
+	// replica is true once a frame from a leader has been applied.
+	replica     bool
+	subscribers map[*subscriber]bool
 }
 
 var storeRegexp = regexp.MustCompile("^segment-[0-9]*$")
+var txSegmentRegexp = regexp.MustCompile("^tx-[0-9]*$")
+
+// ErrConflict is returned when committing a write transaction that
+// was started from a root that is not the last committed root anymore.
+var ErrConflict = errors.New("conflicting transaction was commited")
+
+// ErrReadOnly is returned when modifying a store opened with OpenReadOnly.
+var ErrReadOnly = errors.New("store is opened read only")
 
 const MaxSegmentSize = 1024 * 1024 * 1024 * 1024
 
+// Open opens the store in the directory dir, creating an empty one if dir doesn't contain a store.
 func Open(dir string) (*Store, error) {
-	files, err := ioutil.ReadDir(dir)
+	return OpenBackend(NewFileBackend(dir))
+}
+
+// OpenBackend opens the store kept in the backend, creating an empty one if the backend is empty.
+// The backend is closed when the store is closed.
+func OpenBackend(b Backend) (*Store, error) {
+	names, err := b.Names()
 	if err != nil {
-		return nil, errors.Wrapf(err, "while reading dir %s", dir)
+		return nil, err
 	}
 
-	segmentFiles := []string{}
+	segmentNames := []string{}
 
-	for _, f := range files {
-		if f.Mode().IsRegular() && storeRegexp.MatchString(f.Name()) {
-			segmentFiles = append(segmentFiles, filepath.Join(dir, f.Name()))
+	for _, n := range names {
+		if storeRegexp.MatchString(n) {
+			segmentNames = append(segmentNames, n)
+		}
+
+		// tx segments left behind by a crash are never part of a commit
+		if txSegmentRegexp.MatchString(n) {
+			err = b.Remove(n)
+			if err != nil {
+				return nil, errors.Wrapf(err, "while removing stale tx segment %s", n)
+			}
 		}
 	}
 
-	sort.Strings(segmentFiles)
-	l := new(sync.Mutex)
-	wtc := sync.NewCond(l)
+	sort.Strings(segmentNames)
 	st := &Store{
-		dir:                  dir,
-		mu:                   l,
-		writeTransactionCond: wtc,
+		backend:            b,
+		mu:                 new(sync.RWMutex),
+		commitMu:           new(sync.Mutex),
+		activeTransactions: map[uint64]Address{},
 	}
 
-	for _, sf := range segmentFiles {
-		s, err := openSegment(sf, MaxSegmentSize)
+	for _, sn := range segmentNames {
+		s, err := openSegment(b, sn, MaxSegmentSize, false)
 		if err != nil {
 			return nil, err
 		}
@@ -59,14 +86,19 @@ func Open(dir string) (*Store, error) {
 	}
 
 	if len(st.segments) == 0 {
-		s, err := createSegment(filepath.Join(dir, segmentName(1)), MaxSegmentSize, 1)
+		s, err := createSegment(b, segmentName(1), MaxSegmentSize, 1)
 		if err != nil {
 			return nil, err
 		}
 		st.segments = []*segment{s}
 	}
 
-	ca, err := openCommitAddress(filepath.Join(dir, "commitAddress"))
+	ca, err := openCommitAddress(b)
+	if err != nil {
+		return nil, err
+	}
+
+	st.id, err = openStoreID(b, false)
 	if err != nil {
 		return nil, err
 	}
@@ -92,16 +124,77 @@ func Open(dir string) (*Store, error) {
 	}
 
 	st.lastCommitAddress = ca
+	st.root = ca.address()
 
 	return st, nil
 
 }
 
+// OpenReadOnly opens an existing store without modifying any of its files.
+// Write transactions of a read only store fail with ErrReadOnly.
+// Commits made by another process after the store was opened are not visible.
+func OpenReadOnly(dir string) (*Store, error) {
+	b := NewFileBackend(dir)
+
+	names, err := b.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Store{
+		backend:            b,
+		mu:                 new(sync.RWMutex),
+		commitMu:           new(sync.Mutex),
+		activeTransactions: map[uint64]Address{},
+		readOnly:           true,
+	}
+
+	for _, n := range names {
+		if !storeRegexp.MatchString(n) {
+			continue
+		}
+		s, err := openSegment(b, n, MaxSegmentSize, true)
+		if err != nil {
+			st.Close()
+			return nil, err
+		}
+		st.segments = append(st.segments, s)
+	}
+
+	if len(st.segments) == 0 {
+		return nil, errors.Errorf("%s does not contain any segments", dir)
+	}
+
+	ca, err := openCommitAddressReadOnly(b)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	st.lastCommitAddress = ca
+	st.root = ca.address()
+
+	st.id, err = openStoreID(b, true)
+	if err != nil {
+		st.Close()
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// ReadOnly returns true if the store has been opened with OpenReadOnly.
+func (s *Store) ReadOnly() bool {
+	return s.readOnly
+}
+
 func segmentName(startAddress Address) string {
 	return fmt.Sprintf("segment-%016d", startAddress)
 }
 
 func (s *Store) GetBlock(a Address) (BlockReader, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	for _, s := range s.segments {
 		br, err := s.getBlock(a)
 		if err == ErrBlockNotFound {
@@ -120,22 +213,32 @@ func (s *Store) GetBlock(a Address) (BlockReader, error) {
 func (s *Store) Close() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	err := s.lastCommitAddress.close()
-	if err != nil {
-		return errors.Wrap(err, "while cosing last commit address")
+	if s.lastCommitAddress != nil {
+		err := s.lastCommitAddress.close()
+		if err != nil {
+			return errors.Wrap(err, "while cosing last commit address")
+		}
 	}
 
 	for _, seg := range s.segments {
-		err = seg.close()
+		err := seg.close()
 		if err != nil {
 			return errors.Wrap(err, "while closing a segment")
 		}
 	}
-	return nil
+
+	return s.backend.Close()
 }
 
 func (s *Store) NewReadTransaction() *ReadTransaction {
-	return &ReadTransaction{s}
+	return &ReadTransaction{s: s}
+}
+
+// NewPinnedReadTransaction returns a read transaction and the last commited root.
+// Blocks reachable from the root stay readable until the transaction is closed.
+func (s *Store) NewPinnedReadTransaction() (*ReadTransaction, Address) {
+	id, root := s.pinRoot()
+	return &ReadTransaction{s: s, id: id}, root
 }
 
 func (s *Store) nextAddress() Address {
@@ -143,45 +246,66 @@ func (s *Store) nextAddress() Address {
 	return ls.endAddress()
 }
 
-func (s *Store) txRolledBack() {
+// LastCommitAddress returns the address of the last commited root.
+func (s *Store) LastCommitAddress() Address {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.root
+}
+
+// OldestActiveRoot returns the oldest root a write transaction
+// that is still in progress has been started from.
+// Returns false if there are no transactions in progress.
+func (s *Store) OldestActiveRoot() (Address, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	oldest := NilAddress
+	for _, r := range s.activeTransactions {
+		if oldest == NilAddress || r < oldest {
+			oldest = r
+		}
+	}
+
+	return oldest, oldest != NilAddress
+}
+
+// pinRoot returns the last commited root and keeps the segments it uses
+// from being removed until txFinished is called with the returned id.
+func (s *Store) pinRoot() (uint64, Address) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextTransactionID++
+	id := s.nextTransactionID
+	s.activeTransactions[id] = s.root
+	return id, s.root
+}
+
+func (s *Store) txFinished(id uint64) {
 	s.mu.Lock()
-	s.writeTransactionInProgress = false
-	s.writeTransactionCond.Broadcast()
+	delete(s.activeTransactions, id)
 	s.mu.Unlock()
 }
 
 func (s *Store) PrintStats() {
-	root := s.lastCommitAddress.address()
-
-	br, err := s.GetBlock(root)
+	st, err := s.Stats()
 	if err != nil {
-		panic(errors.Wrap(err, "while getting reader for the old root"))
+		panic(errors.Wrap(err, "while getting stats"))
 	}
 
-	lda := br.GetLowestDescendentAddress()
-	highest := root + Address(len(br))
-
-	dataUsed := br.GetUsedDataSize()
-
-	total := uint64(highest - lda)
-	garbage := float64(total - dataUsed)
+	garbage := float64(st.OccupiedBytes - st.LiveBytes)
 
 	fmt.Println("-- DBSTATS")
-	fmt.Println("- lowest", lda)
-	fmt.Println("- highest", highest)
-	fmt.Println("- bytes occupied", total)
-	fmt.Println("- data used", dataUsed)
-	fmt.Printf("- garbage %% %.2f\n", (garbage/float64(total))*100.0)
+	fmt.Println("- lowest", st.LowestAddress)
+	fmt.Println("- highest", st.HighestAddress)
+	fmt.Println("- bytes occupied", st.OccupiedBytes)
+	fmt.Println("- data used", st.LiveBytes)
+	fmt.Printf("- garbage %% %.2f\n", (garbage/float64(st.OccupiedBytes))*100.0)
 
 }
 
+// txCommited must be called with commitMu held.
 func (s *Store) txCommited(newRoot Address) (Address, error) {
-	s.mu.Lock()
-	defer func() {
-		s.writeTransactionInProgress = false
-		s.writeTransactionCond.Broadcast()
-		s.mu.Unlock()
-	}()
 	oldRoot := s.lastCommitAddress.address()
 
 	if oldRoot == newRoot {
@@ -212,9 +336,16 @@ func (s *Store) txCommited(newRoot Address) (Address, error) {
 	}
 
 	rolledRoot, err := copyBlocks(s, s.lastSegment(), newRoot, shouldCopy)
+	if err != nil {
+		return NilAddress, errors.Wrap(err, "while compacting")
+	}
 
 	s.lastCommitAddress.setAddress(rolledRoot)
 
+	s.mu.Lock()
+	s.root = rolledRoot
+	s.mu.Unlock()
+
 	err = s.createNewSegmentIfNeeded()
 	if err != nil {
 		return NilAddress, err
@@ -239,14 +370,31 @@ func (s *Store) totalSize() (uint64, error) {
 }
 
 func (s *Store) removeUnusedSegments() error {
-	rootAddress := s.lastCommitAddress.address()
-	rr, err := s.GetBlock(rootAddress)
-	if err != nil {
-		return errors.Wrap(err, "while reading root block")
+	s.mu.RLock()
+	roots := []Address{s.root}
+	for _, r := range s.activeTransactions {
+		roots = append(roots, r)
 	}
-	lowest := rr.GetLowestDescendentAddress()
-	for s.segments[0].endAddress() < lowest {
-		err = s.segments[0].closeAndRemove()
+	s.mu.RUnlock()
+
+	// segments still used by transactions in progress must be kept
+	lowest := NilAddress
+	for i, r := range roots {
+		rr, err := s.GetBlock(r)
+		if err != nil {
+			return errors.Wrap(err, "while reading root block")
+		}
+		lda := rr.GetLowestDescendentAddress()
+		if i == 0 || lda < lowest {
+			lowest = lda
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.segments[0].endAddress() <= lowest {
+		err := s.segments[0].closeAndRemove()
 		if err != nil {
 			return err
 		}
@@ -270,14 +418,16 @@ func (s *Store) createNewSegmentIfNeeded() error {
 
 	addr := lastSeg.endAddress()
 
-	name := filepath.Join(s.dir, segmentName(addr))
+	name := segmentName(addr)
 
-	newSeg, err := createSegment(name, MaxSegmentSize, addr)
+	newSeg, err := createSegment(s.backend, name, MaxSegmentSize, addr)
 	if err != nil {
 		return errors.Wrapf(err, "while creating segment %s", name)
 	}
 
+	s.mu.Lock()
 	s.segments = append(s.segments, newSeg)
+	s.mu.Unlock()
 
 	return nil
 
@@ -285,44 +435,96 @@ func (s *Store) createNewSegmentIfNeeded() error {
 
 const txStartAddress = 0xff00000000000000
 
+// NewWriteTransaction starts a new write transaction from the last commited root.
+// Transactions don't block each other, every one of them writes blocks
+// into its own tx segment.
+// The transaction is rolled back when ctx is done.
 func (s *Store) NewWriteTransaction(ctx context.Context) (*WriteTransaction, Address, error) {
-	go func() {
-		dc := ctx.Done()
-		if dc != nil {
-			select {
-			case <-dc:
-				s.mu.Lock()
-				s.writeTransactionCond.Broadcast()
-				s.mu.Unlock()
-			}
-		}
-	}()
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for {
-		if ctx.Err() != nil {
-			return nil, NilAddress, ctx.Err()
-		}
-		if !s.writeTransactionInProgress {
-			break
-		}
-		s.writeTransactionCond.Wait()
+	err := ctx.Err()
+	if err != nil {
+		return nil, NilAddress, err
+	}
+
+	if s.readOnly || s.isReplica() {
+		return nil, NilAddress, ErrReadOnly
 	}
 
-	s.writeTransactionInProgress = true
+	id, root := s.pinRoot()
 
-	txSegment, err := createSegment(filepath.Join(s.dir, "tx"), MaxSegmentSize, txStartAddress)
+	txSegment, err := createSegment(s.backend, fmt.Sprintf("tx-%d", id), MaxSegmentSize, txStartAddress)
 
 	if err != nil {
+		s.txFinished(id)
 		return nil, NilAddress, errors.Wrap(err, "while creating tx segment")
 	}
 
-	return &WriteTransaction{
+	wtx := &WriteTransaction{
 		s:         s,
+		id:        id,
+		base:      root,
 		txSegment: txSegment,
 		ctx:       ctx,
-	}, s.lastCommitAddress.address(), nil
+		mu:        new(sync.Mutex),
+		done:      make(chan struct{}),
+	}
+
+	go wtx.cancelOnDone()
+
+	return wtx, root, nil
+
+}
+
+// Compact copies all blocks reachable from the last commited root into a new segment
+// and removes segments that are not used anymore.
+// Segments still used by transactions in progress are removed by a later commit.
+// Returns the new root.
+func (s *Store) Compact() (Address, error) {
+	if s.readOnly || s.isReplica() {
+		return NilAddress, ErrReadOnly
+	}
+
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	from := s.nextAddress()
+
+	lastSeg := s.lastSegment()
+	if lastSeg.dataContained() > 0 {
+		addr := lastSeg.endAddress()
+		name := segmentName(addr)
+		newSeg, err := createSegment(s.backend, name, MaxSegmentSize, addr)
+		if err != nil {
+			return NilAddress, errors.Wrapf(err, "while creating segment %s", name)
+		}
+
+		s.mu.Lock()
+		s.segments = append(s.segments, newSeg)
+		s.mu.Unlock()
+	}
+
+	copyAll := func(_, _ Address) bool {
+		return true
+	}
+
+	newRoot, err := copyBlocks(s, s.lastSegment(), s.lastCommitAddress.address(), copyAll)
+	if err != nil {
+		return NilAddress, errors.Wrap(err, "while compacting")
+	}
+
+	s.lastCommitAddress.setAddress(newRoot)
+
+	s.mu.Lock()
+	s.root = newRoot
+	s.mu.Unlock()
+
+	s.publish(from, newRoot)
+
+	err = s.removeUnusedSegments()
+	if err != nil {
+		return NilAddress, err
+	}
 
+	return newRoot, nil
 }
 
 func (s *Store) lastSegment() *segment {
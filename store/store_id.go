@@ -0,0 +1,77 @@
+package store
+
+import (
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+const storeIDName = "storeID"
+
+// StoreIDSize is the number of bytes of a store ID.
+const StoreIDSize = 16
+
+// StoreID identifies a store. It is created randomly together with the store
+// and distinguishes it from other stores, including its backups and replicas.
+type StoreID [StoreIDSize]byte
+
+// openStoreID reads the ID of the store kept in the backend, creating it if needed.
+// Read only stores created before IDs were introduced get a random ID that is not persisted.
+func openStoreID(b Backend, readOnly bool) (StoreID, error) {
+	id := StoreID{}
+
+	found, err := hasFile(b, storeIDName)
+	if err != nil {
+		return id, err
+	}
+
+	if !found {
+		_, err = rand.Read(id[:])
+		if err != nil {
+			return id, errors.Wrap(err, "while generating store id")
+		}
+
+		if readOnly {
+			return id, nil
+		}
+
+		f, err := b.Create(storeIDName, StoreIDSize)
+		if err != nil {
+			return id, err
+		}
+		defer f.Close()
+
+		err = f.Truncate(StoreIDSize)
+		if err != nil {
+			return id, errors.Wrap(err, "while writing store id")
+		}
+
+		copy(f.Bytes(), id[:])
+
+		err = f.Flush()
+		if err != nil {
+			return id, errors.Wrap(err, "while writing store id")
+		}
+
+		return id, nil
+	}
+
+	f, err := b.Open(storeIDName, StoreIDSize, readOnly)
+	if err != nil {
+		return id, err
+	}
+	defer f.Close()
+
+	if f.Size() != StoreIDSize {
+		return id, errors.Errorf("file %s has %d bytes - expected %d", f.Name(), f.Size(), StoreIDSize)
+	}
+
+	copy(id[:], f.Bytes())
+
+	return id, nil
+}
+
+// ID returns the ID of the store.
+func (s *Store) ID() StoreID {
+	return s.id
+}
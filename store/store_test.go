@@ -4,7 +4,10 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/draganm/chaintrackdb/store"
 	"github.com/stretchr/testify/require"
@@ -109,3 +112,313 @@ func TestOpeningExistingStore(t *testing.T) {
 	})
 
 }
+
+func TestConcurrentWriteTransactions(t *testing.T) {
+
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+	defer st.Close()
+
+	t.Run("when I start two write transactions", func(t *testing.T) {
+		tx1, base1, err := st.NewWriteTransaction(context.Background())
+		require.NoError(t, err)
+
+		tx2, base2, err := st.NewWriteTransaction(context.Background())
+		require.NoError(t, err)
+
+		require.Equal(t, base1, base2)
+
+		bw1, err := tx1.AppendBlock(store.TypeBTreeNode, 0, 8)
+		require.NoError(t, err)
+
+		bw2, err := tx2.AppendBlock(store.TypeBTreeNode, 0, 8)
+		require.NoError(t, err)
+
+		t.Run("then the first commit should succeed", func(t *testing.T) {
+			newRoot, err := tx1.Commit(bw1.Address)
+			require.NoError(t, err)
+			require.Equal(t, newRoot, st.LastCommitAddress())
+		})
+
+		t.Run("then the second commit should return conflict", func(t *testing.T) {
+			_, err := tx2.Commit(bw2.Address)
+			require.Equal(t, store.ErrConflict, err)
+		})
+
+		t.Run("when I commit using a rebase function", func(t *testing.T) {
+			tx3, base3, err := st.NewWriteTransaction(context.Background())
+			require.NoError(t, err)
+
+			tx4, _, err := st.NewWriteTransaction(context.Background())
+			require.NoError(t, err)
+
+			bw3, err := tx3.AppendBlock(store.TypeBTreeNode, 0, 8)
+			require.NoError(t, err)
+			_, err = tx3.Commit(bw3.Address)
+			require.NoError(t, err)
+
+			newRoot, err := tx4.CommitFunc(func(base, latest store.Address) (store.Address, error) {
+				require.Equal(t, base3, base)
+				require.NotEqual(t, base, latest)
+				return latest, nil
+			})
+			require.NoError(t, err)
+
+			t.Run("then the latest root should be kept", func(t *testing.T) {
+				require.Equal(t, st.LastCommitAddress(), newRoot)
+			})
+		})
+	})
+
+}
+
+func TestWriteTransactionLifecycle(t *testing.T) {
+
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+	defer st.Close()
+
+	t.Run("when I commit a transaction twice", func(t *testing.T) {
+		tx, root, err := st.NewWriteTransaction(context.Background())
+		require.NoError(t, err)
+
+		_, err = tx.Commit(root)
+		require.NoError(t, err)
+
+		_, err = tx.Commit(root)
+		require.Equal(t, store.ErrTxClosed, err)
+
+		t.Run("then rolling back should be a no-op", func(t *testing.T) {
+			require.NoError(t, tx.Rollback())
+		})
+	})
+
+	t.Run("when the context of a transaction is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		tx, _, err := st.NewWriteTransaction(ctx)
+		require.NoError(t, err)
+
+		_, active := st.OldestActiveRoot()
+		require.True(t, active)
+
+		cancel()
+
+		t.Run("then the transaction should be released", func(t *testing.T) {
+			require.Eventually(t, func() bool {
+				_, active := st.OldestActiveRoot()
+				return !active
+			}, time.Second, time.Millisecond)
+
+			_, err = tx.AppendBlock(store.TypeDataLeaf, 0, 1)
+			require.Equal(t, store.ErrTxClosed, err)
+		})
+
+		t.Run("then rolling back should close the transaction", func(t *testing.T) {
+			require.NoError(t, tx.Rollback())
+		})
+	})
+
+}
+
+func TestWriteTransactionSavepoint(t *testing.T) {
+
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+	defer st.Close()
+
+	tx, _, err := st.NewWriteTransaction(context.Background())
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	t.Run("when I roll back to a savepoint", func(t *testing.T) {
+		sp, err := tx.Savepoint()
+		require.NoError(t, err)
+
+		bw, err := tx.AppendBlock(store.TypeDataLeaf, 0, 8)
+		require.NoError(t, err)
+		require.Equal(t, sp, bw.Address)
+
+		err = tx.RollbackTo(sp)
+		require.NoError(t, err)
+
+		t.Run("then the discarded block should not be found", func(t *testing.T) {
+			_, err = tx.GetBlock(bw.Address)
+			require.Equal(t, store.ErrBlockNotFound, err)
+		})
+
+		t.Run("then the next block should reuse the address", func(t *testing.T) {
+			nbw, err := tx.AppendBlock(store.TypeDataLeaf, 0, 4)
+			require.NoError(t, err)
+			require.Equal(t, sp, nbw.Address)
+		})
+	})
+
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+
+	tx, _, err := st.NewWriteTransaction(context.Background())
+	require.NoError(t, err)
+	bw, err := tx.AppendBlock(store.TypeDataLeaf, 0, 3)
+	require.NoError(t, err)
+	copy(bw.Data, []byte{1, 2, 3})
+	root, err := tx.Commit(bw.Address)
+	require.NoError(t, err)
+
+	require.NoError(t, st.Close())
+
+	t.Run("when I open the store read only", func(t *testing.T) {
+		ro, err := store.OpenReadOnly(td)
+		require.NoError(t, err)
+		defer ro.Close()
+
+		t.Run("then the last commited root should be readable", func(t *testing.T) {
+			require.Equal(t, root, ro.LastCommitAddress())
+			br, err := ro.GetBlock(root)
+			require.NoError(t, err)
+			require.Equal(t, []byte{1, 2, 3}, br.GetData())
+		})
+
+		t.Run("then starting a write transaction should fail", func(t *testing.T) {
+			_, _, err := ro.NewWriteTransaction(context.Background())
+			require.Equal(t, store.ErrReadOnly, err)
+		})
+	})
+
+	t.Run("when I open an empty dir read only", func(t *testing.T) {
+		empty, cleanupEmpty := NewTempDir(t)
+		defer cleanupEmpty()
+
+		_, err := store.OpenReadOnly(empty)
+		t.Run("then I should get an error", func(t *testing.T) {
+			require.Error(t, err)
+		})
+	})
+}
+
+func TestCompact(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+	defer st.Close()
+
+	for i := 0; i < 20; i++ {
+		tx, _, err := st.NewWriteTransaction(context.Background())
+		require.NoError(t, err)
+		bw, err := tx.AppendBlock(store.TypeDataLeaf, 0, 1000)
+		require.NoError(t, err)
+		bw.Data[0] = byte(i)
+		_, err = tx.Commit(bw.Address)
+		require.NoError(t, err)
+	}
+
+	t.Run("when I compact the store", func(t *testing.T) {
+		root, err := st.Compact()
+		require.NoError(t, err)
+
+		t.Run("then only the live blocks should be occupied", func(t *testing.T) {
+			stats, err := st.Stats()
+			require.NoError(t, err)
+			require.Equal(t, root, stats.Root)
+			require.Equal(t, stats.LiveBytes, stats.OccupiedBytes)
+
+			br, err := st.GetBlock(root)
+			require.NoError(t, err)
+			require.Equal(t, byte(19), br.GetData()[0])
+		})
+
+		t.Run("then the unused segments should be removed", func(t *testing.T) {
+			files, err := ioutil.ReadDir(td)
+			require.NoError(t, err)
+			segments := 0
+			for _, f := range files {
+				if strings.HasPrefix(f.Name(), "segment-") {
+					segments++
+				}
+			}
+			require.Equal(t, 1, segments)
+		})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	td, cleanup := NewTempDir(t)
+	defer cleanup()
+
+	st, err := store.Open(td)
+	require.NoError(t, err)
+
+	tx, _, err := st.NewWriteTransaction(context.Background())
+	require.NoError(t, err)
+	leaf, err := tx.AppendBlock(store.TypeDataLeaf, 0, 3)
+	require.NoError(t, err)
+	node, err := tx.AppendBlock(store.TypeDataNode, 1, 8)
+	require.NoError(t, err)
+	require.NoError(t, node.SetChild(0, leaf.Address))
+	root, err := tx.Commit(node.Address)
+	require.NoError(t, err)
+
+	segments := st.Segments()
+	require.NoError(t, st.Close())
+
+	t.Run("when I check a consistent store", func(t *testing.T) {
+		report, err := store.Check(td)
+		require.NoError(t, err)
+		t.Run("then there should be no problems", func(t *testing.T) {
+			require.Empty(t, report.Problems)
+			require.Equal(t, 2, report.Blocks)
+			require.Equal(t, root, report.Root)
+		})
+	})
+
+	t.Run("when I corrupt the lowest descendant address of the root", func(t *testing.T) {
+		var seg store.SegmentInfo
+		for _, s := range segments {
+			if s.StartAddress <= root && root < s.EndAddress {
+				seg = s
+			}
+		}
+		f, err := os.OpenFile(filepath.Join(td, seg.Name), os.O_RDWR, 0600)
+		require.NoError(t, err)
+		_, err = f.WriteAt(make([]byte, 8), int64(root-seg.StartAddress)+16+2+8)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		t.Run("then check should report the problem", func(t *testing.T) {
+			report, err := store.Check(td)
+			require.NoError(t, err)
+			require.False(t, report.OK())
+			require.Len(t, report.Problems, 1)
+			require.Equal(t, root, report.Problems[0].Address)
+		})
+
+		t.Run("when I repair the store", func(t *testing.T) {
+			report, err := store.CheckWithOptions(td, store.CheckOptions{Repair: true})
+			require.NoError(t, err)
+			require.True(t, report.OK())
+			require.Len(t, report.Problems, 1)
+
+			t.Run("then the store should be consistent", func(t *testing.T) {
+				report, err := store.Check(td)
+				require.NoError(t, err)
+				require.Empty(t, report.Problems)
+			})
+		})
+	})
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"encoding/binary"
+	"sync"
 
 	"github.com/pkg/errors"
 )
@@ -16,13 +17,108 @@ type ReaderWriter interface {
 	Writer
 }
 
+// ErrTxClosed is returned when using a transaction that has been commited or rolled back.
+var ErrTxClosed = errors.New("transaction is closed")
+
 type WriteTransaction struct {
 	s         *Store
+	id        uint64
+	base      Address
 	txSegment *segment
 	ctx       context.Context
+
+	mu            *sync.Mutex
+	closed        bool
+	committing    bool
+	segmentClosed bool
+	done          chan struct{}
+}
+
+// end marks the transaction as closed.
+// Returns false if the transaction has already been closed.
+func (w *WriteTransaction) end() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return false
+	}
+	w.closed = true
+	close(w.done)
+	return true
+}
+
+// beginCommit marks the transaction as being commited.
+// Returns false if the transaction has been closed or is already being commited.
+func (w *WriteTransaction) beginCommit() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.committing {
+		return false
+	}
+	w.committing = true
+	return true
+}
+
+// cancel closes the transaction unless it is being commited.
+func (w *WriteTransaction) cancel() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed || w.committing {
+		return false
+	}
+	w.closed = true
+	close(w.done)
+	return true
+}
+
+// Closed returns true if the transaction has been commited, rolled back
+// or its context has been cancelled.
+func (w *WriteTransaction) Closed() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closed
+}
+
+// cancelOnDone rolls back the transaction when ctx is done.
+// The tx segment stays mapped until Rollback or Commit is called,
+// because the owner of the transaction might still be using its blocks.
+func (w *WriteTransaction) cancelOnDone() {
+	select {
+	case <-w.ctx.Done():
+		if w.cancel() {
+			w.s.txFinished(w.id)
+			w.txSegment.remove()
+		}
+	case <-w.done:
+	}
+}
+
+func (w *WriteTransaction) closeSegment() error {
+	w.mu.Lock()
+	if w.segmentClosed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.segmentClosed = true
+	w.mu.Unlock()
+
+	err := w.txSegment.closeAndRemove()
+	if err != nil {
+		return errors.Wrap(err, "while closing and removing tx segment")
+	}
+	return nil
+}
+
+// Base returns the root address the transaction was started from.
+func (w *WriteTransaction) Base() Address {
+	return w.base
 }
 
 func (w *WriteTransaction) AppendBlock(blockType BlockType, numberOfChildren int, dataSize int) (BlockWriter, error) {
+	if w.Closed() {
+		return BlockWriter{}, ErrTxClosed
+	}
+
 	err := w.ctx.Err()
 	if err != nil {
 		return BlockWriter{}, err
@@ -71,6 +167,10 @@ func (w *WriteTransaction) AppendBlock(blockType BlockType, numberOfChildren int
 }
 
 func (w *WriteTransaction) GetBlock(a Address) (BlockReader, error) {
+	if w.Closed() {
+		return nil, ErrTxClosed
+	}
+
 	err := w.ctx.Err()
 	if err != nil {
 		return nil, err
@@ -82,18 +182,71 @@ func (w *WriteTransaction) GetBlock(a Address) (BlockReader, error) {
 	return w.s.GetBlock(a)
 }
 
+// Savepoint returns the address the next block appended to the transaction will get.
+// Passing it to RollbackTo discards all blocks appended after this call.
+func (w *WriteTransaction) Savepoint() (Address, error) {
+	if w.Closed() {
+		return NilAddress, ErrTxClosed
+	}
+	return w.txSegment.endAddress(), nil
+}
+
+// RollbackTo discards all blocks appended to the transaction after
+// the savepoint was created. Blocks appended after the savepoint must not
+// be referenced anymore.
+func (w *WriteTransaction) RollbackTo(savepoint Address) error {
+	if w.Closed() {
+		return ErrTxClosed
+	}
+	return w.txSegment.truncate(savepoint)
+}
+
+// Rollback discards the transaction.
+// Calling Rollback on a closed transaction is a no-op.
 func (w *WriteTransaction) Rollback() error {
-	w.s.txRolledBack()
-	err := w.txSegment.closeAndRemove()
-	if err != nil {
-		return errors.Wrap(err, "while closing and removing tx segment")
+	if w.end() {
+		w.s.txFinished(w.id)
 	}
-	return nil
+	return w.closeSegment()
+}
+
+// Commit commits a as the new root.
+// Returns ErrConflict if another transaction has been commited since
+// this transaction was started.
+func (w *WriteTransaction) Commit(a Address) (Address, error) {
+	return w.CommitFunc(func(base, latest Address) (Address, error) {
+		if base != latest {
+			return NilAddress, ErrConflict
+		}
+		return a, nil
+	})
 }
 
-func (w *WriteTransaction) Commit(a Address) (newRoot Address, err error) {
+// CommitFunc commits the root returned by f.
+// f is called while holding the commit lock with the root this transaction
+// was started from and the last commited root, which gives the caller
+// a chance to validate and rebase the transaction's changes onto the latest root.
+// Blocks written by f into the transaction are commited as well.
+func (w *WriteTransaction) CommitFunc(f func(base, latest Address) (Address, error)) (newRoot Address, err error) {
+
+	if !w.beginCommit() {
+		w.closeSegment()
+		return NilAddress, ErrTxClosed
+	}
+
+	defer w.closeSegment()
+	defer w.s.txFinished(w.id)
+	defer w.end()
 
-	defer w.txSegment.closeAndRemove()
+	w.s.commitMu.Lock()
+	defer w.s.commitMu.Unlock()
+
+	from := w.s.nextAddress()
+
+	a, err := f(w.base, w.s.lastCommitAddress.address())
+	if err != nil {
+		return NilAddress, err
+	}
 
 	newRoot = a
 	if a >= w.txSegment.startAddress() {
@@ -108,22 +261,53 @@ func (w *WriteTransaction) Commit(a Address) (newRoot Address, err error) {
 
 	}
 
-	return w.s.txCommited(newRoot)
+	newRoot, err = w.s.txCommited(newRoot)
+	if err != nil {
+		return NilAddress, err
+	}
+
+	w.s.publish(from, newRoot)
+
+	return newRoot, nil
 
 }
 
+// copyBlocks appends copies of all blocks reachable from current for which shouldCopy returns true to w.
+// Blocks referenced from more than one parent are copied only once, so shared subtrees stay shared.
 func copyBlocks(r Reader, w *segment, current Address, shouldCopy func(Address, Address) bool) (Address, error) {
+	c := &blockCopier{
+		r:          r,
+		w:          w,
+		shouldCopy: shouldCopy,
+		copied:     map[Address]Address{},
+	}
+	return c.copy(current)
+}
+
+type blockCopier struct {
+	r          Reader
+	w          *segment
+	shouldCopy func(Address, Address) bool
+	copied     map[Address]Address
+}
+
+func (c *blockCopier) copy(current Address) (Address, error) {
 
 	if current == NilAddress {
 		return NilAddress, nil
 	}
 
-	br, err := r.GetBlock(current)
+	na, found := c.copied[current]
+	if found {
+		return na, nil
+	}
+
+	br, err := c.r.GetBlock(current)
 	if err != nil {
 		return NilAddress, errors.Wrapf(err, "while getting block %d", current)
 	}
 
-	if !shouldCopy(current, br.GetLowestDescendentAddress()) {
+	if !c.shouldCopy(current, br.GetLowestDescendentAddress()) {
 		return current, nil
 	}
 
@@ -131,7 +315,7 @@ func copyBlocks(r Reader, w *segment, current Address, shouldCopy func(Address,
 	children := make([]Address, numberOfChildren)
 
 	for i := 0; i < br.NumberOfChildren(); i++ {
-		newAddress, err := copyBlocks(r, w, br.GetChildAddress(i), shouldCopy)
+		newAddress, err := c.copy(br.GetChildAddress(i))
 		if err != nil {
 			return NilAddress, err
 		}
@@ -139,15 +323,15 @@ func copyBlocks(r Reader, w *segment, current Address, shouldCopy func(Address,
 		children[i] = newAddress
 	}
 
-	addr, nbd, err := w.appendBlock(uint64(len(br)))
+	addr, nbd, err := c.w.appendBlock(uint64(len(br)))
 	if err != nil {
 		return NilAddress, errors.Wrap(err, "while appending block")
 	}
 
 	copy(nbd, br)
 
-	// set total data to block size
-	binary.BigEndian.PutUint64(nbd[2:], uint64(len(nbd)))
+	// set total data to block size, keeping the shared flag
+	binary.BigEndian.PutUint64(nbd[2:], uint64(len(nbd))|(binary.BigEndian.Uint64(br[2:])&sharedFlag))
 
 	// set lowest address to block address
 	binary.BigEndian.PutUint64(nbd[2+8:], uint64(addr))
@@ -167,7 +351,7 @@ func copyBlocks(r Reader, w *segment, current Address, shouldCopy func(Address,
 	// TODO: write children first, then create a new block
 
 	bw := BlockWriter{
-		st:          r,
+		st:          c.r,
 		Address:     addr,
 		BlockReader: nbr,
 		Data:        nbr.GetData(),
@@ -180,6 +364,8 @@ func copyBlocks(r Reader, w *segment, current Address, shouldCopy func(Address,
 		}
 	}
 
+	c.copied[current] = addr
+
 	return addr, nil
 
 }
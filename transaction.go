@@ -0,0 +1,26 @@
+package chaintrackdb
+
+// Reader reads values and maps of a database.
+// It is implemented by ReadTransaction and WriteTransaction,
+// so code depending on it can be used with either of them,
+// with a remote client or with the in-memory memdb package.
+type Reader interface {
+	Get(path string) ([]byte, error)
+	Exists(path string) (bool, error)
+	Count(path string) (uint64, error)
+	ForEachKey(path string, f func(key string) error) error
+}
+
+// ReadWriter reads and modifies values and maps of a database.
+// It is implemented by WriteTransaction.
+type ReadWriter interface {
+	Reader
+	Put(path string, d []byte) error
+	PutAll(path string, d []byte) error
+	CreateMap(path string) error
+	CreateMapAll(path string) error
+	Delete(path string) error
+}
+
+var _ Reader = (*ReadTransaction)(nil)
+var _ ReadWriter = (*WriteTransaction)(nil)
@@ -0,0 +1,129 @@
+package chaintrackdb
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	serrors "errors"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ValueType is the type of a value, recorded together with the value's data.
+type ValueType byte
+
+const (
+	// ValueTypeBytes is the type of values stored with Put.
+	ValueTypeBytes ValueType = iota
+	ValueTypeUint64
+	ValueTypeString
+	ValueTypeJSON
+)
+
+var valueTypeNames = map[ValueType]string{
+	ValueTypeBytes:  "Bytes",
+	ValueTypeUint64: "Uint64",
+	ValueTypeString: "String",
+	ValueTypeJSON:   "JSON",
+}
+
+func (v ValueType) String() string {
+	n, found := valueTypeNames[v]
+	if found {
+		return n
+	}
+	return fmt.Sprintf("Undefined value type %d", v)
+}
+
+// ErrWrongType is returned when reading a value as a type different from the one it was stored with.
+var ErrWrongType = serrors.New("wrong value type")
+
+func (w *WriteTransaction) getTyped(path string, expected ValueType) ([]byte, error) {
+	vt, d, err := w.get(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if vt != expected {
+		return nil, errors.Wrapf(ErrWrongType, "value of %q is %s, not %s", path, vt, expected)
+	}
+
+	return d, nil
+}
+
+// PutUint64 stores an unsigned integer.
+func (w *WriteTransaction) PutUint64(path string, v uint64) error {
+	d := make([]byte, 8)
+	binary.BigEndian.PutUint64(d, v)
+	return w.put(path, ValueTypeUint64, d, false)
+}
+
+// GetUint64 reads an unsigned integer stored with PutUint64 or Increment.
+func (w *WriteTransaction) GetUint64(path string) (uint64, error) {
+	d, err := w.getTyped(path, ValueTypeUint64)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(d) != 8 {
+		return 0, errors.Errorf("malformed uint64 value of %q", path)
+	}
+
+	return binary.BigEndian.Uint64(d), nil
+}
+
+// Increment adds delta to the unsigned integer stored at path and returns the new value.
+// Value that does not exist is treated as 0.
+func (w *WriteTransaction) Increment(path string, delta uint64) (uint64, error) {
+	v, err := w.GetUint64(path)
+	if err != nil && err != ErrNotFound {
+		return 0, err
+	}
+
+	v += delta
+
+	err = w.PutUint64(path, v)
+	if err != nil {
+		return 0, err
+	}
+
+	return v, nil
+}
+
+// PutString stores a string.
+func (w *WriteTransaction) PutString(path string, s string) error {
+	return w.put(path, ValueTypeString, []byte(s), false)
+}
+
+// GetString reads a string stored with PutString.
+func (w *WriteTransaction) GetString(path string) (string, error) {
+	d, err := w.getTyped(path, ValueTypeString)
+	if err != nil {
+		return "", err
+	}
+	return string(d), nil
+}
+
+// PutJSON stores the JSON encoding of v.
+func (w *WriteTransaction) PutJSON(path string, v interface{}) error {
+	d, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "while encoding JSON value of %q", path)
+	}
+	return w.put(path, ValueTypeJSON, d, false)
+}
+
+// GetJSON decodes the value stored with PutJSON into v.
+func (w *WriteTransaction) GetJSON(path string, v interface{}) error {
+	d, err := w.getTyped(path, ValueTypeJSON)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(d, v)
+	if err != nil {
+		return errors.Wrapf(err, "while decoding JSON value of %q", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package chaintrackdb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/draganm/chaintrackdb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedValues(t *testing.T) {
+	db, cleanup := NewEmptyDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	type doc struct {
+		Name string
+		Tags []string
+	}
+
+	t.Run("when I put typed values", func(t *testing.T) {
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			err := tx.PutUint64("int", 42)
+			require.NoError(t, err)
+
+			err = tx.PutString("str", "hello")
+			require.NoError(t, err)
+
+			err = tx.PutJSON("json", doc{Name: "abc", Tags: []string{"x"}})
+			require.NoError(t, err)
+
+			return tx.CreateMap("map")
+		})
+		require.NoError(t, err)
+
+		t.Run("then I should get the values back", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				i, err := tx.GetUint64("int")
+				require.NoError(t, err)
+				require.Equal(t, uint64(42), i)
+
+				s, err := tx.GetString("str")
+				require.NoError(t, err)
+				require.Equal(t, "hello", s)
+
+				var d doc
+				err = tx.GetJSON("json", &d)
+				require.NoError(t, err)
+				require.Equal(t, doc{Name: "abc", Tags: []string{"x"}}, d)
+
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then Get should return the encoded value", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				d, err := tx.Get("str")
+				require.NoError(t, err)
+				require.Equal(t, []byte("hello"), d)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+
+		t.Run("then reading a value as a different type should fail", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.GetUint64("str")
+				require.Equal(t, chaintrackdb.ErrWrongType, errors.Cause(err))
+
+				var d doc
+				err = tx.GetJSON("int", &d)
+				require.Equal(t, chaintrackdb.ErrWrongType, errors.Cause(err))
+
+				err = tx.GetJSON("map", &d)
+				require.Equal(t, chaintrackdb.ErrIsMap, errors.Cause(err))
+
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("when I increment a counter", func(t *testing.T) {
+		var v uint64
+		err := db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+			var err error
+			_, err = tx.Increment("counter", 3)
+			require.NoError(t, err)
+			v, err = tx.Increment("counter", 2)
+			return err
+		})
+		require.NoError(t, err)
+
+		t.Run("then the counter should start from 0", func(t *testing.T) {
+			require.Equal(t, uint64(5), v)
+		})
+
+		t.Run("then incrementing a non-integer value should fail", func(t *testing.T) {
+			err = db.WriteTransaction(ctx, func(tx *chaintrackdb.WriteTransaction) error {
+				_, err := tx.Increment("str", 1)
+				return err
+			})
+			require.Equal(t, chaintrackdb.ErrWrongType, errors.Cause(err))
+		})
+	})
+
+}
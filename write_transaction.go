@@ -1,7 +1,9 @@
 package chaintrackdb
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 
 	serrors "errors"
@@ -13,9 +15,34 @@ import (
 	"github.com/pkg/errors"
 )
 
+// WriteTransaction is an optimistic transaction.
+// Transactions run concurrently, each one records the paths it has read and
+// the changes it has made. On commit the transaction is validated against
+// transactions commited in the meantime and its changes are replayed
+// onto the latest root.
 type WriteTransaction struct {
-	root store.Address
-	swt  *store.WriteTransaction
+	db     *DB
+	root   store.Address
+	swt    *store.WriteTransaction
+	reads  [][]string
+	writes []write
+
+	lastSavepointID uint64
+	savepoints      []uint64
+
+	indexes         map[string]*index
+	indexGeneration uint64
+
+	mergeOperators mergeOperators
+}
+
+// write is a change made by the transaction that can be re-applied
+// to a different root.
+type write struct {
+	path  []string
+	apply func(root store.Address) (store.Address, error)
+	// merge is true for writes that don't depend on the previous value at path.
+	merge bool
 }
 
 func (d *DB) NewWriteTransaction(ctx context.Context) (*WriteTransaction, error) {
@@ -24,35 +51,57 @@ func (d *DB) NewWriteTransaction(ctx context.Context) (*WriteTransaction, error)
 		return nil, errors.Wrap(err, "while creating store transaction")
 	}
 
+	d.indexMu.RLock()
+	defer d.indexMu.RUnlock()
+
+	indexes := make(map[string]*index, len(d.indexes))
+	for n, idx := range d.indexes {
+		indexes[n] = idx
+	}
+
+	d.mergeMu.RLock()
+	defer d.mergeMu.RUnlock()
+
+	ops := make(mergeOperators, len(d.mergeOperators))
+	for n, o := range d.mergeOperators {
+		ops[n] = o
+	}
+
 	return &WriteTransaction{
-		root: root,
-		swt:  swt,
+		db:              d,
+		root:            root,
+		swt:             swt,
+		indexes:         indexes,
+		indexGeneration: d.indexGeneration,
+		mergeOperators:  ops,
 	}, nil
 }
 
+// WriteTransaction runs f in a new write transaction and commits it if f doesn't return an error.
+// ErrConflict is returned if the transaction could not be commited
+// because of a concurrent transaction, in which case f can be retried.
 func (d *DB) WriteTransaction(ctx context.Context, f func(tx *WriteTransaction) error) error {
 
-	swt, root, err := d.s.NewWriteTransaction(ctx)
+	tx, err := d.NewWriteTransaction(ctx)
 	if err != nil {
-		return errors.Wrap(err, "while creating store transaction")
-	}
-
-	tx := &WriteTransaction{
-		root: root,
-		swt:  swt,
+		return err
 	}
 
 	err = f(tx)
 
 	if err != nil {
-		rbe := swt.Rollback()
+		rbe := tx.Rollback()
 		if rbe != nil {
 			return errors.Wrap(err, "while rolling back transaction")
 		}
 		return err
 	}
 
-	_, err = swt.Commit(tx.root)
+	err = tx.Commit()
+
+	if err == ErrConflict {
+		return err
+	}
 
 	if err != nil {
 		return errors.Wrap(err, "while commiting transaction")
@@ -62,7 +111,48 @@ func (d *DB) WriteTransaction(ctx context.Context, f func(tx *WriteTransaction)
 }
 
 func (w *WriteTransaction) CreateMap(path string) error {
-	return w.modifyPath(path, func(ad store.Address, key string) (store.Address, error) {
+	return w.modifyPath(path, false, func(ad store.Address, key string) (store.Address, error) {
+		addr, err := btree.CreateEmpty(w.swt)
+		if err != nil {
+			return store.NilAddress, errors.Wrap(err, "while creating empty map")
+		}
+
+		return btree.Put(w.swt, ad, []byte(key), addr)
+	})
+}
+
+// Delete removes the value or the map with all its contents stored at path.
+func (w *WriteTransaction) Delete(path string) error {
+	return w.modifyPath(path, false, func(ad store.Address, key string) (store.Address, error) {
+		na, err := btree.Delete(w.swt, ad, []byte(key))
+		if err == btree.ErrNotFound {
+			return store.NilAddress, ErrNotFound
+		}
+		return na, err
+	})
+}
+
+// CreateMapAll creates the map and all missing maps on the path, like mkdir -p.
+// Existing maps are left as they are.
+// Returns ErrNotMap if any element of the path is a value.
+func (w *WriteTransaction) CreateMapAll(path string) error {
+	return w.modifyPath(path, true, func(ad store.Address, key string) (store.Address, error) {
+		existing, err := btree.Get(w.swt, ad, []byte(key))
+		if err == nil {
+			br, err := w.swt.GetBlock(existing)
+			if err != nil {
+				return store.NilAddress, errors.Wrap(err, "while reading existing element")
+			}
+			if br.Type() != store.TypeBTreeNode {
+				return store.NilAddress, ErrNotMap
+			}
+			return ad, nil
+		}
+
+		if err != btree.ErrNotFound {
+			return store.NilAddress, err
+		}
+
 		addr, err := btree.CreateEmpty(w.swt)
 		if err != nil {
 			return store.NilAddress, errors.Wrap(err, "while creating empty map")
@@ -72,44 +162,213 @@ func (w *WriteTransaction) CreateMap(path string) error {
 	})
 }
 
+// Commit commits the transaction.
+// Returns ErrConflict if a concurrently commited transaction has changed
+// any of the paths read or written by this transaction.
 func (w *WriteTransaction) Commit() error {
-	_, err := w.swt.Commit(w.root)
-	return err
+	return w.db.commit(w)
+}
+
+// Rollback discards all changes made by the transaction.
+// Rolling back a transaction that has already been closed is a no-op,
+// so it is safe to defer Rollback right after creating a transaction.
+func (w *WriteTransaction) Rollback() error {
+	return w.swt.Rollback()
+}
+
+// ErrTxClosed is returned when using a transaction that has been commited,
+// rolled back or whose context has been cancelled.
+var ErrTxClosed = store.ErrTxClosed
+
+func (w *WriteTransaction) checkOpen() error {
+	if w.swt.Closed() {
+		return ErrTxClosed
+	}
+	return nil
+}
+
+// rebase re-applies all changes of the transaction onto the given root.
+func (w *WriteTransaction) rebase(root store.Address) (store.Address, error) {
+	var err error
+	for _, wr := range w.writes {
+		root, err = wr.apply(root)
+		if err == ErrNotFound || err == ErrNotMap {
+			return store.NilAddress, ErrConflict
+		}
+		if err != nil {
+			return store.NilAddress, errors.Wrapf(err, "while rebasing write of %q", dbpath.Join(wr.path...))
+		}
+	}
+	return root, nil
 }
 
 const dataSegSize = 60 * 1024
 const dataFanout = 128
 
 func (w *WriteTransaction) Put(path string, d []byte) error {
+	return w.put(path, ValueTypeBytes, d, false)
+}
+
+// PutAll puts the value creating all missing maps on the path.
+// Returns ErrNotMap if any of the path's parents is a value.
+func (w *WriteTransaction) PutAll(path string, d []byte) error {
+	return w.put(path, ValueTypeBytes, d, true)
+}
+
+func (w *WriteTransaction) put(path string, vt ValueType, d []byte, createParents bool) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
 
-	dataAddress, err := data.StoreData(w.swt, d, dataSegSize, dataFanout)
+	pth, err := dbpath.Split(path)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", path)
+	}
+
+	// the value is not stored if the path can't be written
+	err = checkKeySizes(pth)
+	if err != nil {
+		return err
+	}
+
+	dataAddress, err := storeValue(w.swt, vt, d)
+	if err != nil {
+		return err
+	}
+
+	return w.modifyPath(path, createParents, func(ad store.Address, key string) (store.Address, error) {
+		return btree.Put(w.swt, ad, []byte(key), dataAddress)
+	})
+}
+
+// PutFrom stores the value read from r at path without holding all of it in memory.
+func (w *WriteTransaction) PutFrom(path string, r io.Reader) error {
+	return w.putFrom(path, r, false)
+}
+
+// PutAllFrom stores the value read from r at path creating all missing maps on the path.
+func (w *WriteTransaction) PutAllFrom(path string, r io.Reader) error {
+	return w.putFrom(path, r, true)
+}
+
+func (w *WriteTransaction) putFrom(path string, r io.Reader, createParents bool) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
+	pth, err := dbpath.Split(path)
+	if err != nil {
+		return errors.Wrapf(err, "while parsing dbpath %q", path)
+	}
+
+	err = checkKeySizes(pth)
+	if err != nil {
+		return err
+	}
+
+	dw := data.NewDataWriter(w.swt, dataSegSize, dataFanout)
+
+	_, err = io.Copy(dw, r)
 	if err != nil {
 		return errors.Wrap(err, "while storing data")
 	}
 
-	return w.modifyPath(path, func(ad store.Address, key string) (store.Address, error) {
-		if err != nil {
-			return store.NilAddress, errors.Wrap(err, "while putting data")
-		}
+	dataAddress, err := dw.Finish()
+	if err != nil {
+		return errors.Wrap(err, "while storing data")
+	}
 
+	return w.modifyPath(path, createParents, func(ad store.Address, key string) (store.Address, error) {
 		return btree.Put(w.swt, ad, []byte(key), dataAddress)
 	})
 }
 
+func storeValue(st store.ReaderWriter, vt ValueType, d []byte) (store.Address, error) {
+	var dataAddress store.Address
+	var err error
+	if vt == ValueTypeBytes {
+		dataAddress, err = data.StoreData(st, d, dataSegSize, dataFanout)
+	} else {
+		dataAddress, err = data.StoreTypedData(st, byte(vt), d, dataSegSize, dataFanout)
+	}
+	if err != nil {
+		return store.NilAddress, errors.Wrap(err, "while storing data")
+	}
+	return dataAddress, nil
+}
+
 func (w *WriteTransaction) Get(path string) ([]byte, error) {
+	_, d, err := w.get(path)
+	return d, err
+}
 
+// GetReader returns a reader of the value at path that doesn't hold all of it in memory.
+// The reader can be used until the transaction is closed.
+func (w *WriteTransaction) GetReader(path string) (io.Reader, error) {
 	addr, err := w.pathElementAddress(path)
 	if err != nil {
 		return nil, err
 	}
 
-	r, err := data.NewReader(addr, w.swt)
+	_, dataAddress, err := data.ValueType(w.swt, addr)
+	if err == data.ErrNotData {
+		// merge operands have to be folded in memory
+		_, d, err := readValue(w.swt, w.mergeOperators, addr)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(d), nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "while reading value type")
+	}
+
+	dr, err := data.NewReader(dataAddress, w.swt)
 	if err != nil {
 		return nil, errors.Wrap(err, "while creating data reader")
 	}
 
-	return ioutil.ReadAll(r)
+	return dr, nil
+}
+
+func (w *WriteTransaction) get(path string) (ValueType, []byte, error) {
+
+	addr, err := w.pathElementAddress(path)
+	if err != nil {
+		return ValueTypeBytes, nil, err
+	}
+
+	return readValue(w.swt, w.mergeOperators, addr)
+
+}
+
+// readValue reads the value stored at addr, folding merge operands if needed.
+func readValue(r store.Reader, ops mergeOperators, addr store.Address) (ValueType, []byte, error) {
+	vt, dataAddress, err := data.ValueType(r, addr)
+	if err == data.ErrNotData {
+		_, _, _, merr := data.MergeNode(r, addr)
+		if merr == nil {
+			return foldMergeNode(r, ops, addr)
+		}
+		return ValueTypeBytes, nil, ErrIsMap
+	}
+	if err != nil {
+		return ValueTypeBytes, nil, errors.Wrap(err, "while reading value type")
+	}
+
+	dr, err := data.NewReader(dataAddress, r)
+	if err != nil {
+		return ValueTypeBytes, nil, errors.Wrap(err, "while creating data reader")
+	}
+
+	d, err := ioutil.ReadAll(dr)
+	if err != nil {
+		return ValueTypeBytes, nil, errors.Wrap(err, "while reading data")
+	}
 
+	return ValueType(vt), d, nil
 }
 
 func (w *WriteTransaction) Exists(path string) (bool, error) {
@@ -134,26 +393,77 @@ func (w *WriteTransaction) Count(path string) (uint64, error) {
 		return 0, err
 	}
 
-	return btree.Count(w.swt, addr)
+	cnt, err := btree.Count(w.swt, addr)
+	if err == btree.ErrNotBTreeNode {
+		return 0, ErrNotMap
+	}
+
+	return cnt, err
 
 }
 
+// ForEachKey calls f with every key of the map at path, in the key order.
+func (w *WriteTransaction) ForEachKey(path string, f func(key string) error) error {
+	addr, err := w.pathElementAddress(path)
+	if err != nil {
+		return err
+	}
+
+	return forEachKey(w.swt, addr, f)
+}
+
 var ErrNotFound = serrors.New("not found")
 
+// ErrIsMap is returned when reading a value from a path that contains a map.
+var ErrIsMap = serrors.New("path is a map")
+
+// ErrNotMap is returned when using a value as a map.
+var ErrNotMap = serrors.New("path is not a map")
+
+// ErrKeyTooLarge is returned when writing to a path with a segment larger than btree.MaxKeySize.
+var ErrKeyTooLarge = btree.ErrKeyTooLarge
+
+func checkKeySizes(parts []string) error {
+	for i, p := range parts {
+		if len(p) > btree.MaxKeySize {
+			name := p[:32]
+			return errors.Wrapf(ErrKeyTooLarge, "segment %d (%q...) has %d bytes, max is %d", i, name, len(p), btree.MaxKeySize)
+		}
+	}
+	return nil
+}
+
 func (w *WriteTransaction) pathElementAddress(path string) (store.Address, error) {
+	err := w.checkOpen()
+	if err != nil {
+		return store.NilAddress, err
+	}
+
 	parts, err := dbpath.Split(path)
 	if err != nil {
 		return store.NilAddress, err
 	}
 
-	ad := w.root
+	w.reads = append(w.reads, parts)
 
-	for _, p := range parts[:len(parts)] {
-		ad, err = btree.Get(w.swt, ad, []byte(p))
+	return pathElementAddress(w.swt, w.root, parts)
+}
+
+func pathElementAddress(r store.Reader, root store.Address, parts []string) (store.Address, error) {
+	ad := root
+
+	var err error
+
+	for _, p := range parts {
+		ad, err = btree.Get(r, ad, []byte(p))
 		if err == btree.ErrNotFound {
 			return store.NilAddress, ErrNotFound
 		}
 
+		if err == btree.ErrNotBTreeNode {
+			return store.NilAddress, ErrNotMap
+		}
+
 		if err != nil {
 			return store.NilAddress, err
 		}
@@ -162,20 +472,48 @@ func (w *WriteTransaction) pathElementAddress(path string) (store.Address, error
 	return ad, nil
 }
 
-func (w *WriteTransaction) modifyPath(path string, f func(ad store.Address, key string) (store.Address, error)) error {
+// modifyPath calls f with the address of the map containing the last element of the path
+// and writes the changed map back to the root.
+// If createParents is true, missing maps on the path are created.
+func (w *WriteTransaction) modifyPath(path string, createParents bool, f func(ad store.Address, key string) (store.Address, error)) error {
+	err := w.checkOpen()
+	if err != nil {
+		return err
+	}
+
 	pth, err := dbpath.Split(path)
 	if err != nil {
 		return errors.Wrapf(err, "while parsing dbpath %q", path)
 	}
-	nr, err := modifyPath(w.swt, w.root, pth, f)
+
+	err = checkKeySizes(pth)
+	if err != nil {
+		return err
+	}
+
+	return w.withIndexes(pth, func() error {
+		return w.applyWrite(write{
+			path: pth,
+			apply: func(root store.Address) (store.Address, error) {
+				return modifyPath(w.swt, root, pth, createParents, f)
+			},
+		})
+	})
+}
+
+// applyWrite applies the write to the transaction's root and records it,
+// so it can be replayed when rebasing the transaction.
+func (w *WriteTransaction) applyWrite(wr write) error {
+	nr, err := wr.apply(w.root)
 	if err != nil {
 		return errors.Wrap(err, "while modifying path")
 	}
 	w.root = nr
+	w.writes = append(w.writes, wr)
 	return nil
 }
 
-func modifyPath(st store.ReaderWriter, ad store.Address, path []string, f func(ad store.Address, key string) (store.Address, error)) (store.Address, error) {
+func modifyPath(st store.ReaderWriter, ad store.Address, path []string, createParents bool, f func(ad store.Address, key string) (store.Address, error)) (store.Address, error) {
 
 	if len(path) == 0 {
 		return store.NilAddress, errors.New("attempted to modify parent of root")
@@ -183,19 +521,33 @@ func modifyPath(st store.ReaderWriter, ad store.Address, path []string, f func(a
 
 	if len(path) > 1 {
 		ca, err := btree.Get(st, ad, []byte(path[0]))
+		if err == btree.ErrNotFound && createParents {
+			ca, err = btree.CreateEmpty(st)
+			if err != nil {
+				return store.NilAddress, errors.Wrap(err, "while creating missing map")
+			}
+		}
 		if err == btree.ErrNotFound {
 			return store.NilAddress, ErrNotFound
 		}
+		if err == btree.ErrNotBTreeNode {
+			return store.NilAddress, ErrNotMap
+		}
 		if err != nil {
 			return store.NilAddress, err
 		}
-		nca, err := modifyPath(st, ca, path[1:], f)
+		nca, err := modifyPath(st, ca, path[1:], createParents, f)
 		if err != nil {
 			return store.NilAddress, err
 		}
 		return btree.Put(st, ad, []byte(path[0]), nca)
 	}
 
-	return f(ad, path[0])
+	na, err := f(ad, path[0])
+	if err == btree.ErrNotBTreeNode {
+		return store.NilAddress, ErrNotMap
+	}
+
+	return na, err
 
 }